@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 	"github.com/uopensail/recgo-engine/model"
@@ -23,7 +24,118 @@ type AppConfig struct {
 	Feeds                     []model.PipelineConfigure `json:"feeds" yaml:"feeds" toml:"feeds"`
 	Related                   []model.PipelineConfigure `json:"related" yaml:"related" toml:"related"`
 	Indexes                   []ResourceConfig          `json:"indexes" yaml:"indexes" toml:"indexes"`
+	Vectors                   []ResourceConfig          `json:"vectors" yaml:"vectors" toml:"vectors"`
+	Models                    []ResourceConfig          `json:"models" yaml:"models" toml:"models"`
+	Profiles                  []ResourceConfig          `json:"profiles" yaml:"profiles" toml:"profiles"`
+	Lists                     []ResourceConfig          `json:"lists" yaml:"lists" toml:"lists"`
 	Items                     ResourceConfig            `json:"items" yaml:"items" toml:"items"`
+	ActionStore               ActionStoreConfig         `json:"action_store" yaml:"action_store" toml:"action_store"`
+	Clients                   []ClientConfig            `json:"clients" yaml:"clients" toml:"clients"`
+	UserFeatures              UserFeatureConfig         `json:"user_features" yaml:"user_features" toml:"user_features"`
+	DerivedFeatures           []DerivedFeatureConfig    `json:"derived_features" yaml:"derived_features" toml:"derived_features"`
+}
+
+// DerivedFeatureConfig declares a user feature computed at request time by an expression
+// over the user and context features, e.g. {name = "u_activity", expr = "len(u_r_click_ids)"}.
+// Derived features are evaluated in order, so later expressions may use earlier results.
+type DerivedFeatureConfig struct {
+	Name string `json:"name" yaml:"name" toml:"name"`
+	Expr string `json:"expr" yaml:"expr" toml:"expr"`
+}
+
+// UserFeatureConfig configures where user features come from.
+// Providers are fetched concurrently and merged by priority; without providers no fetch happens.
+// With CacheSize and CacheTTL set, merged features are cached per user.
+// URL, Timeout, Features and Client declare a single feature service as in earlier configurations;
+// it is fetched as one more http provider named DefaultFeatureProvider.
+type UserFeatureConfig struct {
+	Providers    []FeatureProviderConfig `json:"providers" yaml:"providers" toml:"providers"`
+	URL          string                  `json:"url" yaml:"url" toml:"url"`                               // feature service endpoint, empty for none
+	Timeout      int                     `json:"timeout" yaml:"timeout" toml:"timeout"`                   // feature service budget in milliseconds
+	Features     []string                `json:"features" yaml:"features" toml:"features"`                // requested feature names, empty for all
+	Client       string                  `json:"client" yaml:"client" toml:"client"`                      // outbound client name, empty for the default client
+	SkipProvided bool                    `json:"skip_provided" yaml:"skip_provided" toml:"skip_provided"` // skip the fetch when the request carries features
+	CacheSize    int                     `json:"cache_size" yaml:"cache_size" toml:"cache_size"`          // users kept in the feature cache, 0 disables the cache
+	CacheTTL     int                     `json:"cache_ttl" yaml:"cache_ttl" toml:"cache_ttl"`             // cached features lifetime in seconds
+}
+
+// DefaultFeatureProvider names the http provider declared by the top-level user feature service keys.
+const DefaultFeatureProvider = "default"
+
+// GetProviders returns the declared providers, including the top-level feature service if any.
+func (conf UserFeatureConfig) GetProviders() []FeatureProviderConfig {
+	if conf.URL == "" {
+		return conf.Providers
+	}
+	providers := make([]FeatureProviderConfig, 0, len(conf.Providers)+1)
+	providers = append(providers, FeatureProviderConfig{
+		Name:     DefaultFeatureProvider,
+		Type:     "http",
+		Timeout:  conf.Timeout,
+		URL:      conf.URL,
+		Features: conf.Features,
+		Client:   conf.Client,
+	})
+	return append(providers, conf.Providers...)
+}
+
+// FeatureProviderConfig configures a named user feature provider.
+// Type selects the source:
+//   - "http": POST {"user_id", "features"} to URL through the outbound client named by Client.
+//   - "redis": GET KeyPrefix+user_id from the Redis-protocol server at Addr; the value is a JSON feature map.
+//   - "file": look the user up in the profile table named by Profile (see AppConfig.Profiles).
+//
+// Feature names are prefixed with Prefix; on conflicts the provider with the higher Priority wins.
+type FeatureProviderConfig struct {
+	Name      string   `json:"name" yaml:"name" toml:"name"`
+	Type      string   `json:"type" yaml:"type" toml:"type"`
+	Prefix    string   `json:"prefix" yaml:"prefix" toml:"prefix"`
+	Priority  int      `json:"priority" yaml:"priority" toml:"priority"`
+	Timeout   int      `json:"timeout" yaml:"timeout" toml:"timeout"`          // fetch budget in milliseconds, 0 = pipeline budget only
+	URL       string   `json:"url" yaml:"url" toml:"url"`                      // http: feature service endpoint
+	Features  []string `json:"features" yaml:"features" toml:"features"`       // http: requested feature names, empty for all
+	Client    string   `json:"client" yaml:"client" toml:"client"`             // http: outbound client name, empty for the default client
+	Addr      string   `json:"addr" yaml:"addr" toml:"addr"`                   // redis: host:port
+	Password  string   `json:"password" yaml:"password" toml:"password"`       // redis: AUTH password
+	DB        int      `json:"db" yaml:"db" toml:"db"`                         // redis: database index
+	KeyPrefix string   `json:"key_prefix" yaml:"key_prefix" toml:"key_prefix"` // redis: key prefix before the user id
+	PoolSize  int      `json:"pool_size" yaml:"pool_size" toml:"pool_size"`    // redis: idle connections kept
+	Profile   string   `json:"profile" yaml:"profile" toml:"profile"`          // file: profile table name
+}
+
+// FetchTimeout returns the fetch budget.
+func (conf FeatureProviderConfig) FetchTimeout() time.Duration {
+	return time.Duration(conf.Timeout) * time.Millisecond
+}
+
+// ClientConfig configures a named outbound HTTP client shared by model and feature calls.
+// Durations are in milliseconds; zero values fall back to the outbound package defaults.
+type ClientConfig struct {
+	Name                string            `json:"name" yaml:"name" toml:"name"`
+	Timeout             int               `json:"timeout" yaml:"timeout" toml:"timeout"`                         // per attempt
+	ConnectTimeout      int               `json:"connect_timeout" yaml:"connect_timeout" toml:"connect_timeout"` // dial timeout
+	MaxIdleConns        int               `json:"max_idle_conns" yaml:"max_idle_conns" toml:"max_idle_conns"`    // idle pool size
+	MaxIdleConnsPerHost int               `json:"max_idle_conns_per_host" yaml:"max_idle_conns_per_host" toml:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int               `json:"max_conns_per_host" yaml:"max_conns_per_host" toml:"max_conns_per_host"`       // 0 = unlimited
+	IdleConnTimeout     int               `json:"idle_conn_timeout" yaml:"idle_conn_timeout" toml:"idle_conn_timeout"`          // idle connection lifetime
+	Retries             int               `json:"retries" yaml:"retries" toml:"retries"`                                        // max retries per call
+	RetryBackoff        int               `json:"retry_backoff" yaml:"retry_backoff" toml:"retry_backoff"`                      // wait before a retry
+	RetryBudget         float64           `json:"retry_budget" yaml:"retry_budget" toml:"retry_budget"`                         // retries allowed per call on average
+	RetryBurst          int               `json:"retry_burst" yaml:"retry_burst" toml:"retry_burst"`                            // retries allowed beyond the budget
+	BreakerThreshold    int               `json:"breaker_threshold" yaml:"breaker_threshold" toml:"breaker_threshold"`          // consecutive failures opening the breaker
+	BreakerOpenTimeout  int               `json:"breaker_open_timeout" yaml:"breaker_open_timeout" toml:"breaker_open_timeout"` // open time before half-open probing
+	BreakerProbes       int               `json:"breaker_probes" yaml:"breaker_probes" toml:"breaker_probes"`                   // concurrent half-open probes
+	Headers             map[string]string `json:"headers" yaml:"headers" toml:"headers"`                                        // extra request headers
+}
+
+// ActionStoreConfig configures the engine-owned store of user exposures and actions.
+// An empty Path disables the store.
+type ActionStoreConfig struct {
+	Path         string `json:"path" yaml:"path" toml:"path"`                            // bolt database file
+	TTL          int    `json:"ttl" yaml:"ttl" toml:"ttl"`                               // record lifetime in seconds
+	MaxRecords   int    `json:"max_records" yaml:"max_records" toml:"max_records"`       // records kept per user and action
+	ExposeAction string `json:"expose_action" yaml:"expose_action" toml:"expose_action"` // action name of recorded exposures
+	QueueSize    int    `json:"queue_size" yaml:"queue_size" toml:"queue_size"`          // pending write buffer size
 }
 
 type SegmentConfig struct {
@@ -47,6 +159,41 @@ type ReportConfig struct {
 	SLSLogConfig  `json:"slslog" yaml:"slslog" toml:"slslog"`
 }
 
+// HasIndex reports whether an inverted index with the given name is declared in Indexes.
+func (conf *AppConfig) HasIndex(name string) bool {
+	return conf != nil && hasResource(conf.Indexes, name)
+}
+
+// HasVector reports whether a vector index with the given name is declared in Vectors.
+func (conf *AppConfig) HasVector(name string) bool {
+	return conf != nil && hasResource(conf.Vectors, name)
+}
+
+// HasModel reports whether a tree model with the given name is declared in Models.
+func (conf *AppConfig) HasModel(name string) bool {
+	return conf != nil && hasResource(conf.Models, name)
+}
+
+// HasProfile reports whether a user profile table with the given name is declared in Profiles.
+func (conf *AppConfig) HasProfile(name string) bool {
+	return conf != nil && hasResource(conf.Profiles, name)
+}
+
+// HasList reports whether an item list with the given name is declared in Lists.
+func (conf *AppConfig) HasList(name string) bool {
+	return conf != nil && hasResource(conf.Lists, name)
+}
+
+// hasResource reports whether a resource with the given name is in resources.
+func hasResource(resources []ResourceConfig, name string) bool {
+	for _, res := range resources {
+		if res.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Global AppConfig instance
 var AppConfigInstance *AppConfig
 
@@ -10,6 +10,7 @@ require (
 	github.com/go-kratos/kratos/v2 v2.7.0
 	github.com/segmentio/analytics-go v3.1.0+incompatible
 	github.com/uopensail/ulib v0.0.22-0.20251223144854-9c6902cf36a2
+	go.etcd.io/bbolt v1.3.10
 	go.uber.org/zap v1.26.0
 	google.golang.org/grpc v1.65.0
 	google.golang.org/protobuf v1.34.1
@@ -0,0 +1,16 @@
+// Package testlog initializes the global logger for tests.
+// Test packages whose code logs through zlog import it for its side effect:
+//
+//	import _ "github.com/uopensail/recgo-engine/internal/testlog"
+package testlog
+
+import (
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+func init() {
+	if zlog.LOG == nil {
+		zlog.LOG = zap.NewNop()
+	}
+}
@@ -12,9 +12,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/recgo-engine/outbound"
 	"github.com/uopensail/recgo-engine/resources"
 	"github.com/uopensail/recgo-engine/services"
+	"github.com/uopensail/recgo-engine/store"
 	"github.com/uopensail/recgo-engine/strategy"
+	"github.com/uopensail/recgo-engine/userfeature"
 	"github.com/uopensail/ulib/prome"
 	"github.com/uopensail/ulib/zlog"
 	"go.uber.org/zap"
@@ -47,10 +50,22 @@ func run(configPath, logDir string) *services.Services {
 		panic(err)
 	}
 
-	// Initialize resource manager and recommendation strategy
+	// Initialize shared outbound clients, resources, user feature providers and recommendation strategy
+	outbound.ClientManagerInstance = outbound.NewClientManager(config.AppConfigInstance.Clients)
 	resources.ResourceManagerInstance = resources.NewResourceManager(config.AppConfigInstance)
+	userfeature.ProvidersInstance = userfeature.NewProviders(config.AppConfigInstance.UserFeatures)
+	userfeature.DerivedInstance = userfeature.NewDerived(config.AppConfigInstance.DerivedFeatures)
 	strategy.StrategyInstance = strategy.NewStrategy(config.AppConfigInstance)
 
+	// Open the action store if configured
+	if config.AppConfigInstance.ActionStore.Path != "" {
+		actionStore, err := store.NewActionStore(config.AppConfigInstance.ActionStore)
+		if err != nil {
+			panic(err)
+		}
+		store.ActionStoreInstance = actionStore
+	}
+
 	// Initialize logger
 	zlog.InitLogger(config.AppConfigInstance.ProjectName, config.AppConfigInstance.Debug, logDir)
 
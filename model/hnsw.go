@@ -0,0 +1,253 @@
+package model
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// hnswM is the number of neighbours kept per node on the upper layers.
+	hnswM = 16
+	// hnswMaxM0 is the number of neighbours kept per node on layer 0.
+	hnswMaxM0 = 2 * hnswM
+	// hnswEfConstruction is the candidate list size used while building the graph.
+	hnswEfConstruction = 200
+)
+
+// hnsw is a Hierarchical Navigable Small World graph for approximate nearest neighbour search.
+// Vectors are expected to be L2-normalized, so inner product equals cosine similarity.
+// The graph is built once and is read-only afterwards, which makes search safe for concurrent use.
+type hnsw struct {
+	vectors   [][]float32 // node id -> vector
+	links     [][][]int32 // node id -> layer -> neighbour ids
+	entry     int32       // entry point on the top layer
+	maxLevel  int         // highest layer in the graph
+	levelMult float64     // normalization factor for level generation
+	rng       *rand.Rand  // level generator, only used while building
+}
+
+// newHNSW builds an HNSW graph over the given normalized vectors.
+func newHNSW(vectors [][]float32) *hnsw {
+	g := &hnsw{
+		vectors:   vectors,
+		links:     make([][][]int32, len(vectors)),
+		entry:     -1,
+		levelMult: 1 / math.Log(float64(hnswM)),
+		rng:       rand.New(rand.NewSource(int64(len(vectors)))),
+	}
+	for i := range vectors {
+		g.insert(int32(i))
+	}
+	g.rng = nil
+	return g
+}
+
+// similarity returns the inner product of two vectors.
+func similarity(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// normalize scales v to unit length in place. Zero vectors are left unchanged.
+func normalize(v []float32) {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return
+	}
+	inv := float32(1 / math.Sqrt(norm))
+	for i := range v {
+		v[i] *= inv
+	}
+}
+
+// insert adds node id into the graph.
+func (g *hnsw) insert(id int32) {
+	level := int(math.Floor(-math.Log(1-g.rng.Float64()) * g.levelMult))
+	g.links[id] = make([][]int32, level+1)
+
+	if g.entry < 0 {
+		g.entry = id
+		g.maxLevel = level
+		return
+	}
+
+	query := g.vectors[id]
+	cur := g.entry
+	for l := g.maxLevel; l > level; l-- {
+		cur = g.greedy(query, cur, l)
+	}
+
+	eps := []int32{cur}
+	for l := minLevel(level, g.maxLevel); l >= 0; l-- {
+		candidates := g.searchLayer(query, eps, hnswEfConstruction, l)
+		maxM := hnswM
+		if l == 0 {
+			maxM = hnswMaxM0
+		}
+
+		neighbours := make([]int32, 0, hnswM)
+		for i := 0; i < len(candidates) && i < hnswM; i++ {
+			neighbours = append(neighbours, candidates[i].id)
+		}
+		g.links[id][l] = neighbours
+
+		for _, n := range neighbours {
+			g.links[n][l] = append(g.links[n][l], id)
+			if len(g.links[n][l]) > maxM {
+				g.shrink(n, l, maxM)
+			}
+		}
+
+		eps = eps[:0]
+		for _, c := range candidates {
+			eps = append(eps, c.id)
+		}
+	}
+
+	if level > g.maxLevel {
+		g.maxLevel = level
+		g.entry = id
+	}
+}
+
+// shrink keeps only the maxM most similar neighbours of node on layer l.
+func (g *hnsw) shrink(node int32, l int, maxM int) {
+	links := g.links[node][l]
+	sort.Slice(links, func(i, j int) bool {
+		return similarity(g.vectors[node], g.vectors[links[i]]) > similarity(g.vectors[node], g.vectors[links[j]])
+	})
+	g.links[node][l] = links[:maxM]
+}
+
+// greedy walks layer l from cur towards the node most similar to query.
+func (g *hnsw) greedy(query []float32, cur int32, l int) int32 {
+	best := similarity(query, g.vectors[cur])
+	for changed := true; changed; {
+		changed = false
+		for _, n := range g.links[cur][l] {
+			if sim := similarity(query, g.vectors[n]); sim > best {
+				best, cur, changed = sim, n, true
+			}
+		}
+	}
+	return cur
+}
+
+// searchLayer returns up to ef nodes on layer l closest to query, sorted by similarity in descending order.
+func (g *hnsw) searchLayer(query []float32, eps []int32, ef int, l int) []hnswNode {
+	visited := make(map[int32]struct{}, ef*4)
+	candidates := &hnswMaxHeap{}
+	results := &hnswMinHeap{}
+
+	for _, ep := range eps {
+		visited[ep] = struct{}{}
+		node := hnswNode{id: ep, sim: similarity(query, g.vectors[ep])}
+		heap.Push(candidates, node)
+		heap.Push(results, node)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswNode)
+		if results.Len() >= ef && c.sim < (*results)[0].sim {
+			break
+		}
+		if l >= len(g.links[c.id]) {
+			continue
+		}
+		for _, n := range g.links[c.id][l] {
+			if _, ok := visited[n]; ok {
+				continue
+			}
+			visited[n] = struct{}{}
+			sim := similarity(query, g.vectors[n])
+			if results.Len() < ef || sim > (*results)[0].sim {
+				node := hnswNode{id: n, sim: sim}
+				heap.Push(candidates, node)
+				heap.Push(results, node)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	ret := make([]hnswNode, results.Len())
+	for i := len(ret) - 1; i >= 0; i-- {
+		ret[i] = heap.Pop(results).(hnswNode)
+	}
+	return ret
+}
+
+// search returns the k nodes most similar to the normalized query.
+func (g *hnsw) search(query []float32, k int, ef int) []hnswNode {
+	if g.entry < 0 || k <= 0 {
+		return nil
+	}
+	if ef < k {
+		ef = k
+	}
+
+	cur := g.entry
+	for l := g.maxLevel; l > 0; l-- {
+		cur = g.greedy(query, cur, l)
+	}
+
+	ret := g.searchLayer(query, []int32{cur}, ef, 0)
+	if len(ret) > k {
+		ret = ret[:k]
+	}
+	return ret
+}
+
+// minLevel returns the smaller of a and b.
+func minLevel(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// hnswNode is a graph node with its similarity to the current query.
+type hnswNode struct {
+	id  int32
+	sim float32
+}
+
+// hnswMaxHeap pops the most similar node first.
+type hnswMaxHeap []hnswNode
+
+func (h hnswMaxHeap) Len() int           { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool { return h[i].sim > h[j].sim }
+func (h hnswMaxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswMaxHeap) Push(x any)        { *h = append(*h, x.(hnswNode)) }
+func (h *hnswMaxHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// hnswMinHeap pops the least similar node first.
+type hnswMinHeap []hnswNode
+
+func (h hnswMinHeap) Len() int           { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool { return h[i].sim < h[j].sim }
+func (h hnswMinHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswMinHeap) Push(x any)        { *h = append(*h, x.(hnswNode)) }
+func (h *hnswMinHeap) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func Test_HNSWRecall(t *testing.T) {
+	const (
+		n   = 2000
+		dim = 16
+		k   = 10
+	)
+	rng := rand.New(rand.NewSource(7))
+	randomVector := func() []float32 {
+		v := make([]float32, dim)
+		for i := range v {
+			v[i] = float32(rng.NormFloat64())
+		}
+		normalize(v)
+		return v
+	}
+
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = randomVector()
+	}
+	graph := newHNSW(vectors)
+
+	hits, total := 0, 0
+	for q := 0; q < 50; q++ {
+		query := randomVector()
+
+		// Brute force ground truth
+		ids := make([]int, n)
+		for i := range ids {
+			ids[i] = i
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return similarity(query, vectors[ids[i]]) > similarity(query, vectors[ids[j]])
+		})
+		truth := make(map[int32]struct{}, k)
+		for _, id := range ids[:k] {
+			truth[int32(id)] = struct{}{}
+		}
+
+		got := graph.search(query, k, 64)
+		if len(got) != k {
+			t.Fatalf("expected %d results, got %d", k, len(got))
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i].sim > got[i-1].sim {
+				t.Fatalf("results not sorted by similarity")
+			}
+		}
+		for _, node := range got {
+			if _, ok := truth[node.id]; ok {
+				hits++
+			}
+		}
+		total += k
+	}
+
+	if recall := float64(hits) / float64(total); recall < 0.9 {
+		t.Fatalf("recall@%d too low: %.3f", k, recall)
+	}
+}
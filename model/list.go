@@ -0,0 +1,79 @@
+package model
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// ItemList is an ordered list of item keys maintained by operators, e.g. items to promote.
+type ItemList struct {
+	keys       []string // item keys in file order
+	filePath   string   // Source file path
+	updateTime int64    // UNIX timestamp when the list was last updated
+}
+
+// NewItemList loads an ItemList from a text file with one item key per line.
+// Blank lines and lines starting with "#" are skipped; duplicate keys are kept once.
+func NewItemList(filePath string) (Resource, error) {
+	stat := prome.NewStat("NewItemList")
+	defer stat.End()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		zlog.LOG.Error("ItemList.FileOpenError", zap.String("filePath", filePath), zap.Error(err))
+		stat.MarkErr()
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &ItemList{
+		keys:     make([]string, 0, 64),
+		filePath: filePath,
+	}
+	seen := make(map[string]struct{}, 64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key := strings.TrimSpace(scanner.Text())
+		if key == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		list.keys = append(list.keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		zlog.LOG.Error("ItemList.ScannerError", zap.Error(err))
+		stat.MarkErr()
+		return nil, err
+	}
+
+	list.updateTime = time.Now().Unix()
+	stat.SetCounter(len(list.keys))
+	zlog.LOG.Info("ItemList.LoadComplete",
+		zap.String("filePath", filePath),
+		zap.Int("total_keys", len(list.keys)))
+	return list, nil
+}
+
+// GetKeys returns the item keys in file order. The slice must not be modified.
+func (l *ItemList) GetKeys() []string {
+	return l.keys
+}
+
+// GetUpdateTime returns the UNIX timestamp of the last data update.
+func (l *ItemList) GetUpdateTime() int64 {
+	return l.updateTime
+}
+
+// GetURL returns the source file path of the list.
+func (l *ItemList) GetURL() string {
+	return l.filePath
+}
@@ -1,8 +1,10 @@
 package model
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/uopensail/ulib/zlog"
 	"go.uber.org/zap"
@@ -12,10 +14,38 @@ import (
 // ================= Constants =================
 //
 
+// Frequency mode constants define what a frequency rule does to items over the threshold.
+const (
+	FreqModeFilter = "filter" // Exclude the items from recall (default)
+	FreqModeDemote = "demote" // Keep the items and attach a score penalty
+)
+
+// Penalty type constants define how a demote frequency rule penalizes the score.
+const (
+	PenaltyTypeMultiply = "multiply" // Multiply the score by the penalty (default)
+	PenaltyTypeAdd      = "add"      // Subtract the penalty from the score
+)
+
 // Recall type constants define methods for retrieving candidate items.
 const (
-	RecallTypeMatch = "match" // Match-based recall
-	RecallTypeModel = "model" // Model-based recall
+	RecallTypeMatch     = "match"     // Match-based recall
+	RecallTypeModel     = "model"     // Model-based recall
+	RecallTypeEmbedding = "embedding" // Embedding-based recall over an in-process vector index
+	RecallTypeItemCF    = "item_cf"   // Item-to-item recall seeded from the user's action history
+)
+
+// Embedding source constants define where the query vector of an embedding recall comes from.
+const (
+	EmbeddingSourceUser    = "user"    // Vector from UserContext.Features
+	EmbeddingSourceRelated = "related" // Vector from UserContext.Related
+)
+
+// Merge type constants define methods for merging the outputs of multiple recalls.
+const (
+	MergeTypeRoundRobin = "round_robin" // Layered round-robin in configured recall order
+	MergeTypeQuota      = "quota"       // Round-robin capped by per-recall quotas or ratios
+	MergeTypeWeighted   = "weighted"    // Weighted fusion of normalized recall scores
+	MergeTypeRRF        = "rrf"         // Reciprocal rank fusion
 )
 
 // Rank type constants define methods for ordering candidate items.
@@ -23,6 +53,7 @@ const (
 	RankTypeRule            = "rule"             // Rule-based ranking
 	RankTypeChannelPriority = "channel_priority" // Ranking based on channel priorities
 	RankTypeModel           = "model"            // Model-based ranking
+	RankTypeGBDT            = "gbdt"             // In-process gradient boosted tree ranking
 )
 
 // Constraint type constants define rules applied to the result set.
@@ -30,6 +61,10 @@ const (
 	ConstraintTypeScatter        = "scatter"         // Scatter-based constraint
 	ConstraintTypeWeightAdjusted = "weight_adjusted" // Adjust weights based on conditions
 	ConstraintTypeFixedPosition  = "fixed_position"  // Insert at fixed positions
+	ConstraintTypeSoftScatter    = "soft_scatter"    // Decay scores of repeated values
+	ConstraintTypeMMR            = "mmr"             // Maximal marginal relevance over embeddings
+	ConstraintTypeDPP            = "dpp"             // Determinantal point process slate selection
+	ConstraintTypeForcedInsert   = "forced_insert"   // Insert items that were not recalled
 )
 
 //
@@ -42,6 +77,11 @@ type IFreq interface {
 	GetTimespan() int
 	GetFrequency() int
 	GetAction() string
+	GetField() string
+	GetMode() string
+	GetPenaltyType() string
+	GetPenalty() float32
+	GetScale() bool
 }
 
 // IRecall defines the recall strategy configuration interface.
@@ -68,17 +108,45 @@ type IConstrain interface {
 //
 
 // FreqConfigure defines frequency control parameters for pipeline execution.
+// When Field is empty, the rule caps each item key; otherwise it caps each value of the
+// item feature Field (e.g. category, author_id or a tag list), and every candidate sharing
+// a capped value is affected.
+// Mode "filter" excludes affected items; mode "demote" keeps them and attaches Penalty
+// according to PenaltyType. With Scale, the penalty is applied once per action at or over
+// the threshold, e.g. a multiply penalty 0.8 on an item seen 2 times over becomes 0.8^3.
+// Penalties reach the score through rank or constraint expressions reading them,
+// or through the rank stage with ApplyPenalties.
 type FreqConfigure struct {
-	Name      string `json:"name"`
-	Timespan  int    `json:"timespan"`
-	Frequency int    `json:"frequency"`
-	Action    string `json:"action"`
+	Name        string  `json:"name"`
+	Timespan    int     `json:"timespan"`
+	Frequency   int     `json:"frequency"`
+	Action      string  `json:"action"`
+	Field       string  `json:"field,omitempty"`
+	Mode        string  `json:"mode,omitempty"`
+	PenaltyType string  `json:"penalty_type,omitempty"`
+	Penalty     float32 `json:"penalty,omitempty"`
+	Scale       bool    `json:"scale,omitempty"`
 }
 
 func (f FreqConfigure) GetName() string   { return f.Name }
 func (f FreqConfigure) GetTimespan() int  { return f.Timespan }
 func (f FreqConfigure) GetFrequency() int { return f.Frequency }
 func (f FreqConfigure) GetAction() string { return f.Action }
+func (f FreqConfigure) GetField() string  { return f.Field }
+func (f FreqConfigure) GetMode() string {
+	if f.Mode == "" {
+		return FreqModeFilter
+	}
+	return f.Mode
+}
+func (f FreqConfigure) GetPenaltyType() string {
+	if f.PenaltyType == "" {
+		return PenaltyTypeMultiply
+	}
+	return f.PenaltyType
+}
+func (f FreqConfigure) GetPenalty() float32 { return f.Penalty }
+func (f FreqConfigure) GetScale() bool      { return f.Scale }
 
 //
 // ================= Recall Configurations =================
@@ -98,17 +166,106 @@ func (m MatchRecallConfigure) GetType() string { return m.Type }
 func (m MatchRecallConfigure) GetCount() int   { return m.Count }
 
 // ModelRecallConfigure uses an external model service for item recall.
+// Client names the outbound client from AppConfig.Clients, empty for the default client.
 type ModelRecallConfigure struct {
-	Name  string `json:"name"`
-	Type  string `json:"type"`
-	URL   string `json:"url"`
-	Count int    `json:"count"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Count  int    `json:"count"`
+	Client string `json:"client"`
 }
 
 func (m ModelRecallConfigure) GetName() string { return m.Name }
 func (m ModelRecallConfigure) GetType() string { return m.Type }
 func (m ModelRecallConfigure) GetCount() int   { return m.Count }
 
+// EmbeddingRecallConfigure retrieves the nearest items of a query vector from a vector index.
+// Source selects the feature set holding the vector ("user" or "related"), Field names the
+// float32s feature, and Ef sizes the HNSW candidate list (defaults to Count when smaller).
+type EmbeddingRecallConfigure struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Index  string `json:"index"`
+	Source string `json:"source"`
+	Field  string `json:"field"`
+	Ef     int    `json:"ef"`
+	Count  int    `json:"count"`
+}
+
+func (e EmbeddingRecallConfigure) GetName() string { return e.Name }
+func (e EmbeddingRecallConfigure) GetType() string { return e.Type }
+func (e EmbeddingRecallConfigure) GetCount() int   { return e.Count }
+
+// ItemCFRecallConfigure recalls the neighbours of the user's recently acted items.
+// Index names an inverted index keyed by seed item whose values are similar items.
+// Seeds caps the number of most recent distinct items used as seeds, HalfLife (seconds)
+// decays each seed's weight by its age, and FanOut caps the neighbours taken per seed.
+// Each item's reason is "itemcf:<action>:<seed key>" for its strongest seed.
+type ItemCFRecallConfigure struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Index    string   `json:"index"`
+	Actions  []string `json:"actions"`
+	Seeds    int      `json:"seeds"`
+	HalfLife int      `json:"half_life"`
+	FanOut   int      `json:"fan_out"`
+	Count    int      `json:"count"`
+}
+
+func (i ItemCFRecallConfigure) GetName() string { return i.Name }
+func (i ItemCFRecallConfigure) GetType() string { return i.Type }
+func (i ItemCFRecallConfigure) GetCount() int   { return i.Count }
+
+//
+// ================= Merge Configuration =================
+//
+
+// MergeConfigure defines how recall outputs are merged before ranking.
+// Limit caps the merged size. Quotas (absolute) or Ratios (share of Limit) cap the entries
+// each recall contributes in "quota" mode. Weights scale each recall in "weighted" and "rrf"
+// modes (default 1). K is the RRF rank constant (default 60).
+type MergeConfigure struct {
+	Type    string             `json:"type"`
+	Limit   int                `json:"limit"`
+	Quotas  map[string]int     `json:"quotas"`
+	Ratios  map[string]float32 `json:"ratios"`
+	Weights map[string]float32 `json:"weights"`
+	K       float32            `json:"k"`
+}
+
+//
+// ================= Timeout Configuration =================
+//
+
+// TimeoutConfigure defines per-stage budgets of a pipeline in milliseconds.
+// A non-positive budget adds no deadline beyond the request context's own.
+type TimeoutConfigure struct {
+	Features   int `json:"features"`
+	Recall     int `json:"recall"`
+	Rank       int `json:"rank"`
+	Constrains int `json:"constrains"`
+}
+
+// FeaturesTimeout returns the budget of the user feature fetch.
+func (t TimeoutConfigure) FeaturesTimeout() time.Duration {
+	return time.Duration(t.Features) * time.Millisecond
+}
+
+// RecallTimeout returns the budget of every recall.
+func (t TimeoutConfigure) RecallTimeout() time.Duration {
+	return time.Duration(t.Recall) * time.Millisecond
+}
+
+// RankTimeout returns the budget of the rank stage.
+func (t TimeoutConfigure) RankTimeout() time.Duration {
+	return time.Duration(t.Rank) * time.Millisecond
+}
+
+// ConstrainsTimeout returns the budget of the constraints stage.
+func (t TimeoutConfigure) ConstrainsTimeout() time.Duration {
+	return time.Duration(t.Constrains) * time.Millisecond
+}
+
 //
 // ================= Ranking Configurations =================
 //
@@ -134,30 +291,171 @@ func (c ChannelPriorityRankConfigure) GetName() string { return c.Name }
 func (c ChannelPriorityRankConfigure) GetType() string { return c.Type }
 
 // ModelBasedRankConfigure uses an external model for ranking items.
+// Objectives names the per-item scores (e.g. ctr, cvr) returned by the model; each is stored
+// as a runtime feature of the entry (0 when missing). Fusion is an optional expression over
+// item, user and runtime features plus Weights that produces the final score, e.g.
+// "ctr^w_ctr * cvr^w_cvr * price". A user feature named like a weight overrides it.
+// Without Fusion the model's single score is used.
+// Projection selects the features sent along with the item keys; without it only the
+// user features are sent. BatchSize splits the collection into parallel requests of at most
+// BatchSize items (0 sends one request); Hedge enables hedged duplicate requests.
+// Fallback is the ranker used when every request fails or when the share of items scored by
+// the model is below MinCoverage (0-1).
 type ModelBasedRankConfigure struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
-	URL  string `json:"url"`
+	Name        string               `json:"name"`
+	Type        string               `json:"type"`
+	URL         string               `json:"url"`
+	Objectives  []string             `json:"objectives"`
+	Fusion      string               `json:"fusion"`
+	Weights     map[string]float32   `json:"weights"`
+	Projection  *ProjectionConfigure `json:"projection"`
+	BatchSize   int                  `json:"batch_size"`
+	Hedge       *HedgeConfigure      `json:"hedge"`
+	Fallback    IRank                `json:"fallback,omitempty"`
+	MinCoverage float32              `json:"min_coverage"`
+	Client      string               `json:"client"`
 }
 
 func (m ModelBasedRankConfigure) GetName() string { return m.Name }
 func (m ModelBasedRankConfigure) GetType() string { return m.Type }
 
+// UnmarshalJSON decodes the fallback ranker by its type.
+func (m *ModelBasedRankConfigure) UnmarshalJSON(data []byte) error {
+	type plain ModelBasedRankConfigure
+	var temp struct {
+		plain
+		Fallback json.RawMessage `json:"fallback,omitempty"`
+	}
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+	*m = ModelBasedRankConfigure(temp.plain)
+
+	if len(temp.Fallback) > 0 && string(temp.Fallback) != "null" {
+		var typeCheck struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(temp.Fallback, &typeCheck); err != nil {
+			return fmt.Errorf("failed to get fallback rank type: %w", err)
+		}
+		fallback, err := unmarshalRankConfigure(typeCheck.Type, temp.Fallback)
+		if err != nil {
+			return fmt.Errorf("fallback of rank %s: %w", m.Name, err)
+		}
+		m.Fallback = fallback
+	}
+	return nil
+}
+
+// HedgeConfigure sends a duplicate request when a request is still pending after the
+// Percentile (e.g. 95) of recently observed latencies; the first successful response wins.
+// Delay (ms) is used until enough latencies are observed; 0 disables hedging until then.
+type HedgeConfigure struct {
+	Percentile float32 `json:"percentile"`
+	Delay      int     `json:"delay"`
+}
+
+// ProjectionConfigure lists the features serialized into a rank model request.
+// ItemFields are read from the entry (item features first, then runtime features) and sent as
+// columns aligned with the item keys. UserFields restricts the user features sent; empty sends
+// all of them. Channels and Scores add the recall channels and the pre-rank scores as columns.
+type ProjectionConfigure struct {
+	ItemFields []string `json:"item_fields"`
+	UserFields []string `json:"user_fields"`
+	Channels   bool     `json:"channels"`
+	Scores     bool     `json:"scores"`
+}
+
+// GBDTRankConfigure scores items in-process with a tree model declared in AppConfig.Models.
+// Features optionally names the model's feature slots when the model file uses positional
+// names ("f<i>" or "Column_<i>"). Transform overrides the transform inferred from the model
+// ("raw" or "sigmoid"); BaseScore is added to the summed leaf values before the transform.
+type GBDTRankConfigure struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Model     string   `json:"model"`
+	Features  []string `json:"features"`
+	Transform string   `json:"transform"`
+	BaseScore float32  `json:"base_score"`
+}
+
+func (g GBDTRankConfigure) GetName() string { return g.Name }
+func (g GBDTRankConfigure) GetType() string { return g.Type }
+
+// RankStageConfigure is a stage of the rank chain: a ranker, an optional truncation of its
+// output to the top Truncate entries, and an optional Condition on the user features that
+// must hold for the stage to run. Stages run in the configured order.
+// With ApplyPenalties the frequency penalties of demote rules are applied to the stage's
+// scores before truncation; at most one stage may set it, and its ranker should not read
+// the penalty features itself.
+type RankStageConfigure struct {
+	Rank           IRank
+	Truncate       int
+	Condition      string
+	ApplyPenalties bool
+}
+
 //
 // ================= Constraint Configurations =================
 //
 
 // ScatterBasedConstrainConfigure ensures diversity by scattering items based on a field.
+// At most Count items may share a field value within any Window consecutive positions;
+// a zero Window applies the limit to the whole list.
 type ScatterBasedConstrainConfigure struct {
-	Name  string `json:"name"`
-	Type  string `json:"type"`
-	Field string `json:"field"`
-	Count int    `json:"count"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Field  string `json:"field"`
+	Count  int    `json:"count"`
+	Window int    `json:"window"`
 }
 
 func (s ScatterBasedConstrainConfigure) GetName() string { return s.Name }
 func (s ScatterBasedConstrainConfigure) GetType() string { return s.Type }
 
+// SoftScatterConstrainConfigure trades diversity off against relevance: an entry's score is
+// multiplied by Decay for every entry already placed above it that shares a Field value.
+// Decay must be in (0, 1]; 1 leaves the scores unchanged.
+type SoftScatterConstrainConfigure struct {
+	Name  string  `json:"name"`
+	Type  string  `json:"type"`
+	Field string  `json:"field"`
+	Decay float32 `json:"decay"`
+}
+
+func (s SoftScatterConstrainConfigure) GetName() string { return s.Name }
+func (s SoftScatterConstrainConfigure) GetType() string { return s.Type }
+
+// MMRConstrainConfigure re-ranks the top Window entries by maximal marginal relevance:
+// each position takes the entry maximizing Lambda * relevance - (1 - Lambda) * max similarity
+// to the entries already placed, relevance being the min-max normalized score and similarity
+// the cosine of the Float32s embeddings stored in item feature Field. Window 0 re-ranks all entries.
+type MMRConstrainConfigure struct {
+	Name   string  `json:"name"`
+	Type   string  `json:"type"`
+	Field  string  `json:"field"`
+	Lambda float32 `json:"lambda"`
+	Window int     `json:"window"`
+}
+
+func (m MMRConstrainConfigure) GetName() string { return m.Name }
+func (m MMRConstrainConfigure) GetType() string { return m.Type }
+
+// DPPConstrainConfigure re-ranks the top TopK entries by greedy MAP inference of a determinantal
+// point process whose kernel is L_ij = q_i * S_ij * q_j, S being the cosine similarity of the Float32s
+// embeddings in item feature Field and q_i = exp(Theta / (2 * (1 - Theta)) * relevance_i).
+// Theta in [0, 1) trades quality (towards 1) against diversity (towards 0). TopK 0 re-ranks all entries.
+type DPPConstrainConfigure struct {
+	Name  string  `json:"name"`
+	Type  string  `json:"type"`
+	Field string  `json:"field"`
+	Theta float32 `json:"theta"`
+	TopK  int     `json:"top_k"`
+}
+
+func (d DPPConstrainConfigure) GetName() string { return d.Name }
+func (d DPPConstrainConfigure) GetType() string { return d.Type }
+
 // WeightAdjustedConstrainConfigure adjusts item weights based on conditions.
 type WeightAdjustedConstrainConfigure struct {
 	Name      string  `json:"name"`
@@ -180,25 +478,47 @@ type FixedPositionInsertedConstrainConfigure struct {
 func (f FixedPositionInsertedConstrainConfigure) GetName() string { return f.Name }
 func (f FixedPositionInsertedConstrainConfigure) GetType() string { return f.Type }
 
+// ForcedInsertConstrainConfigure inserts items that were not necessarily recalled at fixed Positions
+// when Condition (over user features, empty for always) holds. Items come from exactly one source:
+// the candidates of Key in inverted index Index, the explicit Items, or the item list resource List.
+// Items already in the collection, unknown or filtered by frequency rules are skipped.
+type ForcedInsertConstrainConfigure struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Index     string   `json:"index"`
+	Key       string   `json:"key"`
+	Items     []string `json:"items"`
+	List      string   `json:"list"`
+	Positions []int    `json:"positions"`
+	Condition string   `json:"condition"`
+}
+
+func (f ForcedInsertConstrainConfigure) GetName() string { return f.Name }
+func (f ForcedInsertConstrainConfigure) GetType() string { return f.Type }
+
 //
 // ================= Pipeline Configuration =================
 //
 
 // PipelineConfigure holds the entire recommendation pipeline configuration.
 type PipelineConfigure struct {
-	Name       string       `json:"name"`
-	Freqs      []IFreq      `json:"freqs"`
-	Recalls    []IRecall    `json:"recalls"`
-	Rank       IRank        `json:"rank,omitempty"`
-	Constrains []IConstrain `json:"constrains"`
+	Name       string                `json:"name"`
+	Timeouts   TimeoutConfigure      `json:"timeouts"`
+	Freqs      []IFreq               `json:"freqs"`
+	Recalls    []IRecall             `json:"recalls"`
+	Merge      MergeConfigure        `json:"merge"`
+	Rank       []*RankStageConfigure `json:"rank,omitempty"`
+	Constrains []IConstrain          `json:"constrains"`
 }
 
 // UnmarshalJSON customizes JSON decoding for PipelineConfigure.
 func (p *PipelineConfigure) UnmarshalJSON(data []byte) error {
 	var temp struct {
 		Name       string            `json:"name"`
+		Timeouts   TimeoutConfigure  `json:"timeouts"`
 		Freqs      []FreqConfigure   `json:"freqs"`
 		Recalls    []json.RawMessage `json:"recalls"`
+		Merge      MergeConfigure    `json:"merge"`
 		Rank       json.RawMessage   `json:"rank,omitempty"`
 		Constrains []json.RawMessage `json:"constrains"`
 	}
@@ -209,6 +529,7 @@ func (p *PipelineConfigure) UnmarshalJSON(data []byte) error {
 	}
 
 	p.Name = temp.Name
+	p.Timeouts = temp.Timeouts
 
 	// Frequency configs
 	p.Freqs = make([]IFreq, 0, len(temp.Freqs))
@@ -222,12 +543,15 @@ func (p *PipelineConfigure) UnmarshalJSON(data []byte) error {
 	}
 	zlog.LOG.Info("PipelineConfigure.RecallsLoaded", zap.Int("count", len(p.Recalls)))
 
+	// Merge
+	p.Merge = temp.Merge
+
 	// Rank
 	if len(temp.Rank) > 0 {
 		if err := p.unmarshalRank(temp.Rank); err != nil {
 			return err
 		}
-		zlog.LOG.Info("PipelineConfigure.RankLoaded", zap.String("type", p.Rank.GetType()))
+		zlog.LOG.Info("PipelineConfigure.RankLoaded", zap.Int("stages", len(p.Rank)))
 	}
 
 	// Constraints
@@ -257,14 +581,28 @@ func (p *PipelineConfigure) unmarshalRecalls(rawRecalls []json.RawMessage) error
 				zlog.LOG.Error("PipelineConfigure.UnmarshalRecalls.MatchError", zap.Int("index", i), zap.Error(err))
 				return fmt.Errorf("failed to unmarshal match recall at index %d: %w", i, err)
 			}
-			p.Recalls[i] = config
+			p.Recalls[i] = &config
 		case RecallTypeModel:
 			var config ModelRecallConfigure
 			if err := json.Unmarshal(raw, &config); err != nil {
 				zlog.LOG.Error("PipelineConfigure.UnmarshalRecalls.ModelError", zap.Int("index", i), zap.Error(err))
 				return fmt.Errorf("failed to unmarshal model recall at index %d: %w", i, err)
 			}
-			p.Recalls[i] = config
+			p.Recalls[i] = &config
+		case RecallTypeEmbedding:
+			var config EmbeddingRecallConfigure
+			if err := json.Unmarshal(raw, &config); err != nil {
+				zlog.LOG.Error("PipelineConfigure.UnmarshalRecalls.EmbeddingError", zap.Int("index", i), zap.Error(err))
+				return fmt.Errorf("failed to unmarshal embedding recall at index %d: %w", i, err)
+			}
+			p.Recalls[i] = &config
+		case RecallTypeItemCF:
+			var config ItemCFRecallConfigure
+			if err := json.Unmarshal(raw, &config); err != nil {
+				zlog.LOG.Error("PipelineConfigure.UnmarshalRecalls.ItemCFError", zap.Int("index", i), zap.Error(err))
+				return fmt.Errorf("failed to unmarshal item cf recall at index %d: %w", i, err)
+			}
+			p.Recalls[i] = &config
 		default:
 			return fmt.Errorf("unknown recall type '%s' at index %d", typeCheck.Type, i)
 		}
@@ -272,38 +610,85 @@ func (p *PipelineConfigure) unmarshalRecalls(rawRecalls []json.RawMessage) error
 	return nil
 }
 
+// unmarshalRank decodes the rank chain. It accepts a list of stages,
+// or a single stage object for configurations written before rank chains existed.
 func (p *PipelineConfigure) unmarshalRank(rawRank json.RawMessage) error {
+	rawStages := []json.RawMessage{rawRank}
+	if trimmed := bytes.TrimSpace(rawRank); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &rawStages); err != nil {
+			zlog.LOG.Error("PipelineConfigure.UnmarshalRank.ListError", zap.Error(err))
+			return fmt.Errorf("failed to unmarshal rank stages: %w", err)
+		}
+	}
+
+	p.Rank = make([]*RankStageConfigure, len(rawStages))
+	for i, raw := range rawStages {
+		stage, err := unmarshalRankStage(raw)
+		if err != nil {
+			zlog.LOG.Error("PipelineConfigure.UnmarshalRank.StageError", zap.Int("index", i), zap.Error(err))
+			return fmt.Errorf("rank stage at index %d: %w", i, err)
+		}
+		p.Rank[i] = stage
+	}
+	return nil
+}
+
+// unmarshalRankStage decodes a single rank stage: the ranker configuration by type,
+// plus the stage-level truncate, condition and apply_penalties fields.
+func unmarshalRankStage(rawRank json.RawMessage) (*RankStageConfigure, error) {
 	var typeCheck struct {
-		Type string `json:"type"`
+		Type           string `json:"type"`
+		Truncate       int    `json:"truncate"`
+		Condition      string `json:"condition"`
+		ApplyPenalties bool   `json:"apply_penalties"`
 	}
 	if err := json.Unmarshal(rawRank, &typeCheck); err != nil {
 		zlog.LOG.Error("PipelineConfigure.UnmarshalRank.TypeError", zap.Error(err))
-		return fmt.Errorf("failed to get rank type: %w", err)
+		return nil, fmt.Errorf("failed to get rank type: %w", err)
 	}
 
-	switch typeCheck.Type {
+	rank, err := unmarshalRankConfigure(typeCheck.Type, rawRank)
+	if err != nil {
+		return nil, err
+	}
+	return &RankStageConfigure{
+		Rank:           rank,
+		Truncate:       typeCheck.Truncate,
+		Condition:      typeCheck.Condition,
+		ApplyPenalties: typeCheck.ApplyPenalties,
+	}, nil
+}
+
+// unmarshalRankConfigure decodes a ranker configuration of the given type.
+func unmarshalRankConfigure(rankType string, rawRank json.RawMessage) (IRank, error) {
+	switch rankType {
 	case RankTypeRule:
 		var config RuleBasedRankConfigure
 		if err := json.Unmarshal(rawRank, &config); err != nil {
-			return fmt.Errorf("failed to unmarshal rule rank: %w", err)
+			return nil, fmt.Errorf("failed to unmarshal rule rank: %w", err)
 		}
-		p.Rank = config
+		return &config, nil
 	case RankTypeChannelPriority:
 		var config ChannelPriorityRankConfigure
 		if err := json.Unmarshal(rawRank, &config); err != nil {
-			return fmt.Errorf("failed to unmarshal channel priority rank: %w", err)
+			return nil, fmt.Errorf("failed to unmarshal channel priority rank: %w", err)
 		}
-		p.Rank = config
+		return &config, nil
 	case RankTypeModel:
 		var config ModelBasedRankConfigure
 		if err := json.Unmarshal(rawRank, &config); err != nil {
-			return fmt.Errorf("failed to unmarshal model rank: %w", err)
+			return nil, fmt.Errorf("failed to unmarshal model rank: %w", err)
+		}
+		return &config, nil
+	case RankTypeGBDT:
+		var config GBDTRankConfigure
+		if err := json.Unmarshal(rawRank, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal gbdt rank: %w", err)
 		}
-		p.Rank = config
+		return &config, nil
 	default:
-		return fmt.Errorf("unknown rank type: %s", typeCheck.Type)
+		return nil, fmt.Errorf("unknown rank type: %s", rankType)
 	}
-	return nil
 }
 
 func (p *PipelineConfigure) unmarshalConstrains(rawConstrains []json.RawMessage) error {
@@ -323,19 +708,43 @@ func (p *PipelineConfigure) unmarshalConstrains(rawConstrains []json.RawMessage)
 			if err := json.Unmarshal(raw, &config); err != nil {
 				return fmt.Errorf("failed to unmarshal scatter constraint: %w", err)
 			}
-			p.Constrains[i] = config
+			p.Constrains[i] = &config
+		case ConstraintTypeSoftScatter:
+			var config SoftScatterConstrainConfigure
+			if err := json.Unmarshal(raw, &config); err != nil {
+				return fmt.Errorf("failed to unmarshal soft scatter constraint: %w", err)
+			}
+			p.Constrains[i] = &config
+		case ConstraintTypeMMR:
+			var config MMRConstrainConfigure
+			if err := json.Unmarshal(raw, &config); err != nil {
+				return fmt.Errorf("failed to unmarshal mmr constraint: %w", err)
+			}
+			p.Constrains[i] = &config
+		case ConstraintTypeDPP:
+			var config DPPConstrainConfigure
+			if err := json.Unmarshal(raw, &config); err != nil {
+				return fmt.Errorf("failed to unmarshal dpp constraint: %w", err)
+			}
+			p.Constrains[i] = &config
+		case ConstraintTypeForcedInsert:
+			var config ForcedInsertConstrainConfigure
+			if err := json.Unmarshal(raw, &config); err != nil {
+				return fmt.Errorf("failed to unmarshal forced insert constraint: %w", err)
+			}
+			p.Constrains[i] = &config
 		case ConstraintTypeWeightAdjusted:
 			var config WeightAdjustedConstrainConfigure
 			if err := json.Unmarshal(raw, &config); err != nil {
 				return fmt.Errorf("failed to unmarshal weight adjusted constraint: %w", err)
 			}
-			p.Constrains[i] = config
+			p.Constrains[i] = &config
 		case ConstraintTypeFixedPosition:
 			var config FixedPositionInsertedConstrainConfigure
 			if err := json.Unmarshal(raw, &config); err != nil {
 				return fmt.Errorf("failed to unmarshal fixed position constraint: %w", err)
 			}
-			p.Constrains[i] = config
+			p.Constrains[i] = &config
 		default:
 			return fmt.Errorf("unknown constraint type '%s' at index %d", typeCheck.Type, i)
 		}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalRank(t *testing.T) {
+	var single PipelineConfigure
+	if err := json.Unmarshal([]byte(`{"name":"p","rank":{"name":"r","type":"rule","rule":"1.0"}}`), &single); err != nil {
+		t.Fatal(err)
+	}
+	if len(single.Rank) != 1 || single.Rank[0].Rank.GetName() != "r" {
+		t.Fatalf("single object: unexpected stages %+v", single.Rank)
+	}
+
+	var chain PipelineConfigure
+	raw := `{"name":"p","rank":[
+		{"name":"pre","type":"rule","rule":"1.0","truncate":100,"apply_penalties":true},
+		{"name":"main","type":"model","url":"http://rank","condition":"u_level > 1"}
+	]}`
+	if err := json.Unmarshal([]byte(raw), &chain); err != nil {
+		t.Fatal(err)
+	}
+	if len(chain.Rank) != 2 {
+		t.Fatalf("chain: got %d stages, want 2", len(chain.Rank))
+	}
+	if chain.Rank[0].Truncate != 100 || !chain.Rank[0].ApplyPenalties || chain.Rank[0].Rank.GetType() != RankTypeRule {
+		t.Errorf("stage 0: unexpected %+v", chain.Rank[0])
+	}
+	if chain.Rank[1].Condition != "u_level > 1" || chain.Rank[1].Rank.GetType() != RankTypeModel {
+		t.Errorf("stage 1: unexpected %+v", chain.Rank[1])
+	}
+}
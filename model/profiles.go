@@ -0,0 +1,99 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/sample"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// Profiles is a table of user features loaded from file, e.g. a daily user profile dump.
+// Rows are kept as raw JSON and decoded on lookup, so every caller gets its own mutable copy.
+type Profiles struct {
+	dict       map[string]string // user id -> JSON features
+	filePath   string            // Source file path
+	updateTime int64             // UNIX timestamp when data was last updated
+}
+
+// NewProfiles loads Profiles from a tab-delimited file.
+// File format: each line contains "<user_id>\t<json>", the JSON being a feature map
+// in the same format as item features.
+// Lines with an invalid format or invalid JSON are skipped with a warning.
+func NewProfiles(filePath string) (Resource, error) {
+	stat := prome.NewStat("NewProfiles")
+	defer stat.End()
+
+	startTime := time.Now()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		zlog.LOG.Error("Profiles.FileOpenError", zap.String("filePath", filePath), zap.Error(err))
+		stat.MarkErr()
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	profiles := &Profiles{
+		dict:     make(map[string]string, 4096),
+		filePath: filePath,
+	}
+
+	index := 0
+	for scanner.Scan() {
+		index++
+		userId, data, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok || !json.Valid([]byte(data)) {
+			zlog.LOG.Warn("Profiles.SkipLine.InvalidFormat", zap.Int("line_index", index-1))
+			continue
+		}
+		profiles.dict[userId] = data
+	}
+
+	if err := scanner.Err(); err != nil {
+		zlog.LOG.Error("Profiles.ScannerError", zap.Error(err))
+		stat.MarkErr()
+		return nil, err
+	}
+
+	profiles.updateTime = time.Now().Unix()
+	stat.SetCounter(len(profiles.dict))
+
+	zlog.LOG.Info("Profiles.LoadComplete",
+		zap.Int("total_users", len(profiles.dict)),
+		zap.Duration("elapsed", time.Since(startTime)),
+	)
+	return profiles, nil
+}
+
+// Get decodes the features of a user.
+// Returns nil and no error if the user is not in the table.
+func (p *Profiles) Get(userId string) (*sample.MutableFeatures, error) {
+	data, ok := p.dict[userId]
+	if !ok {
+		return nil, nil
+	}
+	features := sample.NewMutableFeatures()
+	if err := sonic.UnmarshalString(data, features); err != nil {
+		return nil, err
+	}
+	return features, nil
+}
+
+// GetUpdateTime returns the UNIX timestamp of the last data update.
+func (p *Profiles) GetUpdateTime() int64 {
+	return p.updateTime
+}
+
+// GetURL returns the source file path of the profile table.
+func (p *Profiles) GetURL() string {
+	return p.filePath
+}
@@ -11,9 +11,11 @@ import (
 // IFilter defines a filter interface used in recommendation pipeline.
 // Exists returns true if the ID should be filtered out.
 // Exclude returns a list of keys to be excluded.
+// Demote attaches the frequency penalties (FreqFactorKey, FreqOffsetKey) to every entry.
 type IFilter interface {
 	Exists(id int) bool // True: filtered, False: pass
 	Exclude() []string
+	Demote(collection Collection)
 }
 
 // Resource defines basic information for loaded resources.
@@ -28,6 +30,14 @@ const (
 	ReasonsKey  = "i_ctx_reasons"
 )
 
+// Keys used in runtime features to store frequency penalties of demote rules.
+// Rank and constraint expressions read them, or the rank stage configured to apply penalties
+// rescales its scores to score * factor + offset. Undemoted entries carry factor 1 and offset 0.
+const (
+	FreqFactorKey = "i_ctx_freq_factor"
+	FreqOffsetKey = "i_ctx_freq_offset"
+)
+
 // Entry represents a candidate item in recommendation pipeline,
 // including its item ID, score, and runtime features (channels, reasons, etc.).
 type Entry struct {
@@ -72,6 +82,17 @@ func (entry *Entry) AddChan(channel string, reason string) {
 	zlog.LOG.Info("Entry.AddChan", zap.Int("id", entry.ID), zap.String("channel", channel), zap.String("reason", reason))
 }
 
+// Clone returns a copy of the entry whose score and runtime features can be changed
+// without affecting the original. Basic features and feature values are shared.
+func (entry *Entry) Clone() *Entry {
+	r := NewRuntime(entry.Runtime.Basic)
+	entry.Runtime.RunTime.ForEach(func(key string, feature sample.Feature) error {
+		r.RunTime.Set(key, feature)
+		return nil
+	})
+	return &Entry{entry.ID, entry.KeyScore, *r}
+}
+
 // MergeChans merges channels and reasons from another Entry into this Entry.
 // The source Entry is not modified.
 func (entry *Entry) MergeChans(src *Entry) {
@@ -107,6 +128,15 @@ func (entry *Entry) MergeChans(src *Entry) {
 // It implements sort.Interface to allow sorting by Score in descending order.
 type Collection []*Entry
 
+// Clone returns a collection of cloned entries in the same order.
+func (c Collection) Clone() Collection {
+	ret := make(Collection, len(c))
+	for i, entry := range c {
+		ret[i] = entry.Clone()
+	}
+	return ret
+}
+
 // Less returns true if entry at index i has a higher score than entry at index j.
 func (c Collection) Less(i, j int) bool {
 	return c[i].KeyScore.Score > c[j].KeyScore.Score
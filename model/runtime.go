@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/uopensail/ulib/sample"
 	"github.com/uopensail/ulib/zlog"
@@ -50,3 +51,32 @@ func (r *Runtime) Set(key string, value sample.Feature) {
 		zap.Any("value", value),
 	)
 }
+
+// Feature2StringSlice converts a sample.Feature value into a slice of strings.
+// This is required wherever item attributes are compared as string keys (scatter, attribute freqs).
+// Float features are not supported and yield nil.
+func Feature2StringSlice(feature sample.Feature) []string {
+	if feature == nil {
+		return nil
+	}
+	switch feature.Type() {
+	case sample.Int64Type:
+		val, _ := feature.GetInt64()
+		return []string{strconv.FormatInt(val, 10)}
+	case sample.Int64sType:
+		val, _ := feature.GetInt64s()
+		ret := make([]string, 0, len(val))
+		for _, v := range val {
+			ret = append(ret, strconv.FormatInt(v, 10))
+		}
+		return ret
+	case sample.StringType:
+		val, _ := feature.GetString()
+		return []string{val}
+	case sample.StringsType:
+		val, _ := feature.GetStrings()
+		return val
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,386 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// Tree model transform constants define how the summed leaf values are turned into a score.
+const (
+	TreeTransformRaw     = "raw"     // Sum of leaf values
+	TreeTransformSigmoid = "sigmoid" // Logistic function of the sum
+)
+
+// LightGBM decision_type bits.
+const (
+	lgbCategoricalMask = 1
+	lgbDefaultLeftMask = 2
+	lgbMissingZero     = 1
+	lgbMissingNaN      = 2
+)
+
+// treeNode is a node of a flattened decision tree.
+// Internal nodes send x to left if x < threshold (or x <= threshold when le is set).
+type treeNode struct {
+	feature     int32   // feature slot, -1 for leaves
+	threshold   float32 // split threshold
+	left        int32   // index of the left child
+	right       int32   // index of the right child
+	missingLeft bool    // missing values go left
+	zeroMissing bool    // zero values are treated as missing
+	le          bool    // split with <= instead of <
+	value       float32 // leaf value
+}
+
+// TreeModel is a gradient boosted tree ensemble loaded from an XGBoost JSON dump
+// or a LightGBM text model, scored in-process.
+// Features are referenced by name; Score reads the feature vector by slot, see GetFeatures.
+type TreeModel struct {
+	features   []string     // slot -> feature name
+	trees      [][]treeNode // flattened trees, node 0 is the root
+	transform  string       // default transform inferred from the model file
+	filePath   string       // Source file path
+	updateTime int64        // UNIX timestamp when the model was last updated
+}
+
+// NewTreeModel loads a TreeModel from a model file.
+// The format is detected from the content:
+//   - XGBoost JSON dump (Booster.get_dump(dump_format="json") joined into a JSON array or
+//     Booster.dump_model(..., dump_format="json")): files starting with "[".
+//     Splits go to "yes" when x < split_condition, and to "missing" when x is missing.
+//   - LightGBM text model (Booster.save_model): any other file.
+//     Splits go left when x <= threshold; decision_type decides the missing direction.
+//     Categorical splits are not supported.
+//
+// Logs:
+// - Error if the file cannot be read or parsed
+// - Info on total trees loaded and time taken
+func NewTreeModel(filePath string) (Resource, error) {
+	stat := prome.NewStat("NewTreeModel")
+	defer stat.End()
+
+	startTime := time.Now()
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		zlog.LOG.Error("TreeModel.FileReadError", zap.String("filePath", filePath), zap.Error(err))
+		stat.MarkErr()
+		return nil, err
+	}
+
+	m := &TreeModel{
+		filePath:  filePath,
+		transform: TreeTransformRaw,
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = m.parseXGBoost(trimmed)
+	} else {
+		err = m.parseLightGBM(data)
+	}
+	if err != nil {
+		zlog.LOG.Error("TreeModel.ParseError", zap.String("filePath", filePath), zap.Error(err))
+		stat.MarkErr()
+		return nil, fmt.Errorf("failed to parse tree model %s: %w", filePath, err)
+	}
+	if len(m.trees) == 0 {
+		stat.MarkErr()
+		return nil, fmt.Errorf("no trees loaded from file %s", filePath)
+	}
+
+	m.updateTime = time.Now().Unix()
+	stat.SetCounter(len(m.trees))
+
+	zlog.LOG.Info("TreeModel.LoadComplete",
+		zap.Int("total_trees", len(m.trees)),
+		zap.Int("total_features", len(m.features)),
+		zap.String("transform", m.transform),
+		zap.Duration("elapsed", time.Since(startTime)),
+	)
+	return m, nil
+}
+
+// xgbNode is a node of an XGBoost JSON dump.
+type xgbNode struct {
+	NodeId         int32      `json:"nodeid"`
+	Split          string     `json:"split"`
+	SplitCondition float32    `json:"split_condition"`
+	Yes            int32      `json:"yes"`
+	No             int32      `json:"no"`
+	Missing        int32      `json:"missing"`
+	Leaf           *float32   `json:"leaf"`
+	Children       []*xgbNode `json:"children"`
+}
+
+// parseXGBoost parses a JSON array of XGBoost tree dumps.
+func (m *TreeModel) parseXGBoost(data []byte) error {
+	var roots []*xgbNode
+	if err := json.Unmarshal(data, &roots); err != nil {
+		return err
+	}
+
+	slots := make(map[string]int32)
+	for i, root := range roots {
+		// Flatten in DFS order, then translate node IDs into array indexes
+		flat := make([]*xgbNode, 0, 64)
+		var walk func(n *xgbNode)
+		walk = func(n *xgbNode) {
+			flat = append(flat, n)
+			for _, child := range n.Children {
+				walk(child)
+			}
+		}
+		walk(root)
+
+		positions := make(map[int32]int32, len(flat))
+		for pos, n := range flat {
+			positions[n.NodeId] = int32(pos)
+		}
+
+		tree := make([]treeNode, len(flat))
+		for pos, n := range flat {
+			if n.Leaf != nil {
+				tree[pos] = treeNode{feature: -1, value: *n.Leaf}
+				continue
+			}
+			yes, ok1 := positions[n.Yes]
+			no, ok2 := positions[n.No]
+			missing, ok3 := positions[n.Missing]
+			if !ok1 || !ok2 || !ok3 {
+				return fmt.Errorf("tree %d: node %d has unknown children", i, n.NodeId)
+			}
+			slot, ok := slots[n.Split]
+			if !ok {
+				slot = int32(len(m.features))
+				slots[n.Split] = slot
+				m.features = append(m.features, n.Split)
+			}
+			tree[pos] = treeNode{
+				feature:     slot,
+				threshold:   n.SplitCondition,
+				left:        yes,
+				right:       no,
+				missingLeft: missing == yes,
+			}
+		}
+		m.trees = append(m.trees, tree)
+	}
+	return nil
+}
+
+// parseLightGBM parses a LightGBM text model.
+func (m *TreeModel) parseLightGBM(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	var block map[string]string
+	flush := func() error {
+		if block == nil {
+			return nil
+		}
+		tree, err := m.buildLightGBMTree(block)
+		if err != nil {
+			return fmt.Errorf("tree %d: %w", len(m.trees), err)
+		}
+		m.trees = append(m.trees, tree)
+		block = nil
+		return nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "end of trees" {
+			break
+		}
+		if strings.HasPrefix(line, "Tree=") {
+			if err := flush(); err != nil {
+				return err
+			}
+			block = make(map[string]string, 16)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if block != nil {
+			block[key] = value
+			continue
+		}
+		switch key {
+		case "feature_names":
+			m.features = strings.Fields(value)
+		case "objective":
+			if strings.HasPrefix(value, "binary") || strings.HasPrefix(value, "cross_entropy") {
+				m.transform = TreeTransformSigmoid
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if len(m.features) == 0 {
+		return fmt.Errorf("feature_names missing")
+	}
+	return nil
+}
+
+// buildLightGBMTree flattens a LightGBM tree block: internal nodes keep their indexes,
+// leaf k is appended at index num_leaves-1+k.
+func (m *TreeModel) buildLightGBMTree(block map[string]string) ([]treeNode, error) {
+	numLeaves, err := strconv.Atoi(block["num_leaves"])
+	if err != nil || numLeaves < 1 {
+		return nil, fmt.Errorf("invalid num_leaves %q", block["num_leaves"])
+	}
+	leafValues, err := parseFloats(block["leaf_value"])
+	if err != nil || len(leafValues) != numLeaves {
+		return nil, fmt.Errorf("invalid leaf_value")
+	}
+
+	internal := numLeaves - 1
+	tree := make([]treeNode, internal+numLeaves)
+	for k, v := range leafValues {
+		tree[internal+k] = treeNode{feature: -1, value: v}
+	}
+	if internal == 0 {
+		return tree, nil
+	}
+
+	splitFeatures, err1 := parseInts(block["split_feature"])
+	thresholds, err2 := parseFloats(block["threshold"])
+	decisionTypes, err3 := parseInts(block["decision_type"])
+	lefts, err4 := parseInts(block["left_child"])
+	rights, err5 := parseInts(block["right_child"])
+	for _, err := range []error{err1, err2, err3, err4, err5} {
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range []int{len(splitFeatures), len(thresholds), len(decisionTypes), len(lefts), len(rights)} {
+		if n != internal {
+			return nil, fmt.Errorf("split arrays mismatch %d internal nodes", internal)
+		}
+	}
+
+	child := func(c int) int32 {
+		if c < 0 {
+			return int32(internal + ^c)
+		}
+		return int32(c)
+	}
+	for i := 0; i < internal; i++ {
+		if decisionTypes[i]&lgbCategoricalMask != 0 {
+			return nil, fmt.Errorf("categorical split is not supported")
+		}
+		if splitFeatures[i] < 0 || splitFeatures[i] >= len(m.features) {
+			return nil, fmt.Errorf("split feature %d out of range", splitFeatures[i])
+		}
+		missingType := (decisionTypes[i] >> 2) & 3
+		tree[i] = treeNode{
+			feature:     int32(splitFeatures[i]),
+			threshold:   thresholds[i],
+			left:        child(lefts[i]),
+			right:       child(rights[i]),
+			missingLeft: decisionTypes[i]&lgbDefaultLeftMask != 0,
+			zeroMissing: missingType == lgbMissingZero,
+			le:          true,
+		}
+		// Without a missing type LightGBM treats NaN as zero
+		if missingType != lgbMissingZero && missingType != lgbMissingNaN {
+			tree[i].missingLeft = 0 <= thresholds[i]
+		}
+	}
+	return tree, nil
+}
+
+// GetFeatures returns the feature names indexed by slot.
+func (m *TreeModel) GetFeatures() []string {
+	return m.features
+}
+
+// GetTransform returns the transform inferred from the model file.
+func (m *TreeModel) GetTransform() string {
+	return m.transform
+}
+
+// Score returns the sum of the leaf values reached by x.
+// x is indexed by slot (see GetFeatures); missing values are NaN.
+func (m *TreeModel) Score(x []float32) float32 {
+	var sum float32
+	for _, tree := range m.trees {
+		idx := int32(0)
+		for {
+			n := &tree[idx]
+			if n.feature < 0 {
+				sum += n.value
+				break
+			}
+			v := x[n.feature]
+			switch {
+			case math.IsNaN(float64(v)) || (n.zeroMissing && v == 0):
+				if n.missingLeft {
+					idx = n.left
+				} else {
+					idx = n.right
+				}
+			case v < n.threshold || (n.le && v == n.threshold):
+				idx = n.left
+			default:
+				idx = n.right
+			}
+		}
+	}
+	return sum
+}
+
+// GetUpdateTime returns the UNIX timestamp when the model was last updated.
+func (m *TreeModel) GetUpdateTime() int64 {
+	return m.updateTime
+}
+
+// GetURL returns the source file path of the model.
+func (m *TreeModel) GetURL() string {
+	return m.filePath
+}
+
+// parseFloats parses a space separated list of floats.
+func parseFloats(s string) ([]float32, error) {
+	fields := strings.Fields(s)
+	ret := make([]float32, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 32)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = float32(v)
+	}
+	return ret, nil
+}
+
+// parseInts parses a space separated list of integers.
+func parseInts(s string) ([]int, error) {
+	fields := strings.Fields(s)
+	ret := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/uopensail/recgo-engine/internal/testlog"
+)
+
+func writeModel(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "model")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTreeModelXGBoost(t *testing.T) {
+	dump := `[
+  {"nodeid":0,"depth":0,"split":"age","split_condition":30,"yes":1,"no":2,"missing":2,"children":[
+    {"nodeid":1,"leaf":0.5},
+    {"nodeid":2,"depth":1,"split":"price","split_condition":10,"yes":3,"no":4,"missing":3,"children":[
+      {"nodeid":3,"leaf":-0.25},
+      {"nodeid":4,"leaf":1}
+    ]}
+  ]},
+  {"nodeid":0,"leaf":0.1}
+]`
+	res, err := NewTreeModel(writeModel(t, dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := res.(*TreeModel)
+	if len(m.GetFeatures()) != 2 || m.GetFeatures()[0] != "age" || m.GetFeatures()[1] != "price" {
+		t.Fatalf("unexpected features %v", m.GetFeatures())
+	}
+
+	nan := float32(math.NaN())
+	cases := []struct {
+		x    []float32
+		want float32
+	}{
+		{[]float32{20, 100}, 0.6},
+		{[]float32{30, 100}, 1.1},  // 30 is not < 30
+		{[]float32{nan, 5}, -0.15}, // missing age goes to "no"
+		{[]float32{40, nan}, -0.15},
+	}
+	for _, c := range cases {
+		if got := m.Score(c.x); math.Abs(float64(got-c.want)) > 1e-6 {
+			t.Errorf("Score(%v) = %f, want %f", c.x, got, c.want)
+		}
+	}
+}
+
+func TestTreeModelLightGBM(t *testing.T) {
+	text := `tree
+version=v3
+num_class=1
+objective=binary sigmoid:1
+feature_names=age price
+
+Tree=0
+num_leaves=3
+split_feature=0 1
+threshold=30 10
+decision_type=2 8
+left_child=-1 -2
+right_child=1 -3
+leaf_value=0.5 -0.25 1
+
+Tree=1
+num_leaves=1
+leaf_value=0.1
+
+end of trees
+`
+	res, err := NewTreeModel(writeModel(t, text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := res.(*TreeModel)
+	if m.GetTransform() != TreeTransformSigmoid {
+		t.Errorf("transform = %s, want sigmoid", m.GetTransform())
+	}
+
+	nan := float32(math.NaN())
+	cases := []struct {
+		x    []float32
+		want float32
+	}{
+		{[]float32{30, 100}, 0.6}, // 30 <= 30 goes left
+		{[]float32{31, 10}, -0.15},
+		{[]float32{nan, 100}, 0.6}, // default left
+		{[]float32{40, nan}, 1.1},  // NaN missing type, default right
+	}
+	for _, c := range cases {
+		if got := m.Score(c.x); math.Abs(float64(got-c.want)) > 1e-6 {
+			t.Errorf("Score(%v) = %f, want %f", c.x, got, c.want)
+		}
+	}
+}
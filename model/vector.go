@@ -0,0 +1,151 @@
+package model
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/bytedance/sonic"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// VectorIndex stores item embeddings in an in-process HNSW graph
+// for approximate nearest neighbour retrieval by cosine similarity.
+type VectorIndex struct {
+	keys       []string // node id -> item key
+	dim        int      // vector dimension
+	graph      *hnsw    // HNSW graph over the normalized vectors
+	filePath   string   // Source file path
+	updateTime int64    // UNIX timestamp when the index was last updated
+}
+
+// NewVectorIndex loads a VectorIndex from a tab-delimited file and builds the HNSW graph.
+// File format: each line contains "<key>\t<json float array>"
+// Example:
+//
+//	item123   [0.12,-0.03,0.88]
+//
+// All vectors must share the same dimension; they are L2-normalized on load.
+// Logs:
+// - Error if file cannot be opened
+// - Warning if a line is skipped due to format or dimension error
+// - Info on total vectors loaded and time taken
+func NewVectorIndex(filePath string) (Resource, error) {
+	stat := prome.NewStat("NewVectorIndex")
+	defer stat.End()
+
+	startTime := time.Now()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		zlog.LOG.Error("VectorIndex.FileOpenError", zap.String("filePath", filePath), zap.Error(err))
+		stat.MarkErr()
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	keys := make([]string, 0, 4096)
+	vectors := make([][]float32, 0, 4096)
+	dim := 0
+	lineIndex := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineIndex++
+		ss := strings.Split(line, "\t")
+		if len(ss) != 2 {
+			zlog.LOG.Warn("VectorIndex.SkipLine.InvalidFormat", zap.Int("line_index", lineIndex))
+			continue
+		}
+
+		var vector []float32
+		if err := sonic.Unmarshal(unsafe.Slice(unsafe.StringData(ss[1]), len(ss[1])), &vector); err != nil {
+			zlog.LOG.Warn("VectorIndex.SkipLine.JSONUnmarshalError", zap.String("key", ss[0]), zap.Error(err))
+			continue
+		}
+		if dim == 0 {
+			dim = len(vector)
+		}
+		if len(vector) == 0 || len(vector) != dim {
+			zlog.LOG.Warn("VectorIndex.SkipLine.DimensionMismatch",
+				zap.String("key", ss[0]),
+				zap.Int("expected", dim),
+				zap.Int("actual", len(vector)))
+			continue
+		}
+
+		normalize(vector)
+		keys = append(keys, ss[0])
+		vectors = append(vectors, vector)
+	}
+
+	if err := scanner.Err(); err != nil {
+		zlog.LOG.Error("VectorIndex.ScannerError", zap.Error(err))
+		stat.MarkErr()
+		return nil, err
+	}
+
+	if len(vectors) == 0 {
+		stat.MarkErr()
+		return nil, fmt.Errorf("no vectors loaded from file %s", filePath)
+	}
+
+	index := &VectorIndex{
+		keys:       keys,
+		dim:        dim,
+		graph:      newHNSW(vectors),
+		filePath:   filePath,
+		updateTime: time.Now().Unix(),
+	}
+	stat.SetCounter(len(keys))
+
+	zlog.LOG.Info("VectorIndex.LoadComplete",
+		zap.Int("total_vectors", len(keys)),
+		zap.Int("dim", dim),
+		zap.Duration("elapsed", time.Since(startTime)),
+	)
+	return index, nil
+}
+
+// Search returns up to k items most similar to the query vector, sorted by score in descending order.
+// ef controls the size of the dynamic candidate list; larger values trade latency for accuracy.
+// Returns an error if the query dimension does not match the index.
+func (idx *VectorIndex) Search(query []float32, k int, ef int) ([]KeyScore, error) {
+	if len(query) != idx.dim {
+		return nil, fmt.Errorf("query dimension %d mismatch index dimension %d", len(query), idx.dim)
+	}
+
+	// Normalize a copy so the caller's features stay untouched
+	q := make([]float32, len(query))
+	copy(q, query)
+	normalize(q)
+
+	nodes := idx.graph.search(q, k, ef)
+	ret := make([]KeyScore, 0, len(nodes))
+	for _, node := range nodes {
+		ret = append(ret, KeyScore{Key: idx.keys[node.id], Score: node.sim})
+	}
+	return ret, nil
+}
+
+// GetDim returns the dimension of the vectors in the index.
+func (idx *VectorIndex) GetDim() int {
+	return idx.dim
+}
+
+// GetUpdateTime returns the UNIX timestamp when the index was last updated.
+func (idx *VectorIndex) GetUpdateTime() int64 {
+	return idx.updateTime
+}
+
+// GetURL returns the source file path of the vector index.
+func (idx *VectorIndex) GetURL() string {
+	return idx.filePath
+}
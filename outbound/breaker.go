@@ -0,0 +1,119 @@
+package outbound
+
+import (
+	"sync"
+	"time"
+)
+
+// Breaker states.
+const (
+	stateClosed   = iota // calls pass through, consecutive failures are counted
+	stateOpen            // calls fail fast until the open timeout expires
+	stateHalfOpen        // a limited number of probe calls decide whether to close again
+)
+
+// breaker is a consecutive-failure circuit breaker with half-open probing.
+// After threshold consecutive failures it opens and rejects calls for openTimeout,
+// then lets up to probes concurrent calls through: one success closes it,
+// one failure opens it again.
+// Every state change starts a new generation; calls report their outcome with the generation
+// they were allowed in, so a call outliving its state (e.g. admitted while closed and finishing
+// while half-open) is ignored instead of acting as a probe.
+type breaker struct {
+	mu          sync.Mutex
+	state       int
+	generation  uint64        // incremented on every state change
+	failures    int           // consecutive failures while closed
+	inflight    int           // probes in flight while half-open
+	openedAt    time.Time     // when the breaker last opened
+	threshold   int           // consecutive failures opening the breaker
+	openTimeout time.Duration // time spent open before probing
+	probes      int           // concurrent probes allowed while half-open
+	now         func() time.Time
+}
+
+// newBreaker creates a closed breaker.
+func newBreaker(threshold int, openTimeout time.Duration, probes int) *breaker {
+	return &breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		probes:      probes,
+		now:         time.Now,
+	}
+}
+
+// allow reports whether a call may proceed, and the generation the call belongs to.
+// An allowed call must be reported with done or release, passing that generation.
+func (b *breaker) allow() (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return b.generation, false
+		}
+		b.transition(stateHalfOpen)
+		fallthrough
+	case stateHalfOpen:
+		if b.inflight >= b.probes {
+			return b.generation, false
+		}
+		b.inflight++
+		return b.generation, true
+	default:
+		return b.generation, true
+	}
+}
+
+// done records the outcome of a call allowed in generation.
+// Outcomes of earlier generations are ignored.
+func (b *breaker) done(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case stateHalfOpen:
+		if success {
+			b.transition(stateClosed)
+		} else {
+			b.open()
+		}
+	case stateClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+// release gives back a call allowed in generation without an outcome,
+// e.g. one canceled by its caller.
+func (b *breaker) release(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if generation == b.generation && b.state == stateHalfOpen {
+		b.inflight--
+	}
+}
+
+// open moves the breaker to the open state, the caller holds mu.
+func (b *breaker) open() {
+	b.transition(stateOpen)
+	b.openedAt = b.now()
+}
+
+// transition starts a new generation in state, the caller holds mu.
+func (b *breaker) transition(state int) {
+	b.state = state
+	b.generation++
+	b.failures = 0
+	b.inflight = 0
+}
@@ -0,0 +1,40 @@
+package outbound
+
+import "sync"
+
+// budget limits retries to a fraction of the call volume, so a struggling backend
+// is not hit by a retry storm. Every call deposits ratio tokens, every retry withdraws one;
+// the balance is capped at burst.
+type budget struct {
+	mu     sync.Mutex
+	tokens float64
+	ratio  float64
+	burst  float64
+}
+
+// newBudget creates a budget starting with a full burst.
+func newBudget(ratio float64, burst int) *budget {
+	return &budget{
+		tokens: float64(burst),
+		ratio:  ratio,
+		burst:  float64(burst),
+	}
+}
+
+// deposit credits one call.
+func (b *budget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.burst)
+}
+
+// withdraw takes the token for one retry, reporting false if the budget is exhausted.
+func (b *budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,196 @@
+package outbound
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// Defaults applied to zero-valued ClientConfig fields.
+const (
+	DefaultTimeout             = 1000 // ms
+	DefaultConnectTimeout      = 200  // ms
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 10
+	DefaultIdleConnTimeout     = 90000 // ms
+	DefaultRetryBackoff        = 10    // ms
+	DefaultRetryBudget         = 0.1   // one retry per ten calls
+	DefaultRetryBurst          = 10
+	DefaultBreakerThreshold    = 5
+	DefaultBreakerOpenTimeout  = 1000 // ms
+	DefaultBreakerProbes       = 1
+)
+
+// ErrCircuitOpen is returned without sending the request while the client's breaker is open.
+var ErrCircuitOpen = errors.New("outbound: circuit open")
+
+// Client is a shared outbound HTTP client with a pooled transport, per-attempt timeout,
+// budgeted retries and a circuit breaker. It is safe for concurrent use.
+type Client struct {
+	name    string
+	conf    config.ClientConfig
+	client  *http.Client
+	breaker *breaker
+	budget  *budget
+	backoff time.Duration
+}
+
+// NewClient creates a Client, filling zero-valued settings with the package defaults.
+func NewClient(conf config.ClientConfig) *Client {
+	pStat := prome.NewStat("Outbound.NewClient")
+	defer pStat.End()
+
+	withDefault := func(v, def int) int {
+		if v <= 0 {
+			return def
+		}
+		return v
+	}
+	ms := func(v int) time.Duration {
+		return time.Duration(v) * time.Millisecond
+	}
+
+	conf.Timeout = withDefault(conf.Timeout, DefaultTimeout)
+	conf.ConnectTimeout = withDefault(conf.ConnectTimeout, DefaultConnectTimeout)
+	conf.MaxIdleConns = withDefault(conf.MaxIdleConns, DefaultMaxIdleConns)
+	conf.MaxIdleConnsPerHost = withDefault(conf.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost)
+	conf.IdleConnTimeout = withDefault(conf.IdleConnTimeout, DefaultIdleConnTimeout)
+	conf.RetryBackoff = withDefault(conf.RetryBackoff, DefaultRetryBackoff)
+	conf.RetryBurst = withDefault(conf.RetryBurst, DefaultRetryBurst)
+	conf.BreakerThreshold = withDefault(conf.BreakerThreshold, DefaultBreakerThreshold)
+	conf.BreakerOpenTimeout = withDefault(conf.BreakerOpenTimeout, DefaultBreakerOpenTimeout)
+	conf.BreakerProbes = withDefault(conf.BreakerProbes, DefaultBreakerProbes)
+	if conf.RetryBudget <= 0 {
+		conf.RetryBudget = DefaultRetryBudget
+	}
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         (&net.Dialer{Timeout: ms(conf.ConnectTimeout), KeepAlive: 30 * time.Second}).DialContext,
+		MaxIdleConns:        conf.MaxIdleConns,
+		MaxIdleConnsPerHost: conf.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     conf.MaxConnsPerHost,
+		IdleConnTimeout:     ms(conf.IdleConnTimeout),
+	}
+
+	zlog.LOG.Info("Outbound.Client.Created",
+		zap.String("name", conf.Name),
+		zap.Int("timeout", conf.Timeout),
+		zap.Int("retries", conf.Retries),
+		zap.Int("breaker_threshold", conf.BreakerThreshold))
+
+	return &Client{
+		name:    conf.Name,
+		conf:    conf,
+		client:  &http.Client{Timeout: ms(conf.Timeout), Transport: transport},
+		breaker: newBreaker(conf.BreakerThreshold, ms(conf.BreakerOpenTimeout), conf.BreakerProbes),
+		budget:  newBudget(conf.RetryBudget, conf.RetryBurst),
+		backoff: ms(conf.RetryBackoff),
+	}
+}
+
+// Name returns the configured client name.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Do sends req with the configured extra headers:
+//  1. Fail fast with ErrCircuitOpen while the breaker is open.
+//  2. Send the attempt; transport errors, 5xx and 429 responses count as failures.
+//  3. Retry failures while retries, the retry budget and the request context allow;
+//     requests whose body cannot be replayed are not retried.
+//
+// Like http.Client.Do, a non-nil response is returned for the last failed status,
+// and the caller must close its body.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	pStat := prome.NewStat(fmt.Sprintf("Outbound.%s.Do", c.name))
+	defer pStat.End()
+
+	for key, value := range c.conf.Headers {
+		req.Header.Set(key, value)
+	}
+	c.budget.deposit()
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if err := c.rewind(req); err != nil {
+				pStat.MarkErr()
+				return nil, err
+			}
+		}
+
+		generation, ok := c.breaker.allow()
+		if !ok {
+			pStat.MarkErr()
+			oStat := prome.NewStat(fmt.Sprintf("Outbound.%s.CircuitOpen", c.name))
+			oStat.MarkErr()
+			oStat.End()
+			return nil, ErrCircuitOpen
+		}
+
+		resp, err := c.client.Do(req)
+		if req.Context().Err() != nil {
+			// Canceled or timed out by the caller, not the backend's fault
+			c.breaker.release(generation)
+			pStat.MarkErr()
+			return resp, err
+		}
+		failed := err != nil || retryable(resp.StatusCode)
+		c.breaker.done(generation, !failed)
+		if !failed {
+			return resp, nil
+		}
+
+		if attempt >= c.conf.Retries || (req.Body != nil && req.GetBody == nil) || !c.budget.withdraw() {
+			pStat.MarkErr()
+			zlog.LOG.Warn("Outbound.Client.Do.Failed",
+				zap.String("name", c.name),
+				zap.String("url", req.URL.String()),
+				zap.Int("attempts", attempt+1),
+				zap.Error(err))
+			return resp, err
+		}
+
+		// Discard the failed response before retrying so its connection is reused
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		prome.NewStat(fmt.Sprintf("Outbound.%s.Retry", c.name)).End()
+
+		timer := time.NewTimer(c.backoff << attempt)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			pStat.MarkErr()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// rewind resets the request body for another attempt.
+func (c *Client) rewind(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
+
+// retryable reports whether a response status is a backend failure worth retrying.
+func retryable(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
@@ -0,0 +1,121 @@
+package outbound
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uopensail/recgo-engine/config"
+	_ "github.com/uopensail/recgo-engine/internal/testlog"
+)
+
+func TestClientRetry(t *testing.T) {
+	// Fails the first attempt, then echoes the body and the extra header
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Echo", r.Header.Get("X-Caller"))
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r.Body)
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	client := NewClient(config.ClientConfig{
+		Name:         "retry",
+		Retries:      1,
+		RetryBackoff: 1,
+		Headers:      map[string]string{"X-Caller": "engine"},
+	})
+	req, _ := http.NewRequest("POST", server.URL, bytes.NewBufferString("payload"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	body.ReadFrom(resp.Body)
+	if resp.StatusCode != http.StatusOK || body.String() != "payload" || resp.Header.Get("X-Echo") != "engine" {
+		t.Errorf("got status %d body %q header %q", resp.StatusCode, body.String(), resp.Header.Get("X-Echo"))
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want 2", calls)
+	}
+}
+
+func TestClientBreaker(t *testing.T) {
+	var healthy atomic.Bool
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(config.ClientConfig{Name: "breaker", BreakerThreshold: 2, BreakerOpenTimeout: 50})
+	do := func() error {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := client.Do(req)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// Two failures open the breaker, the next call fails fast without reaching the server
+	do()
+	do()
+	if err := do(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls while open, want 2", calls)
+	}
+
+	// After the open timeout a successful probe closes the breaker
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	if err := do(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if err := do(); err != nil {
+		t.Fatalf("closed: %v", err)
+	}
+}
+
+func TestBreakerStaleCall(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(1, time.Second, 1)
+	b.now = func() time.Time { return now }
+
+	// A call admitted while closed is still running when another failure opens the breaker
+	slow, _ := b.allow()
+	failing, _ := b.allow()
+	b.done(failing, false)
+
+	// Once half-open, only the probe decides
+	now = now.Add(2 * time.Second)
+	probe, ok := b.allow()
+	if !ok {
+		t.Fatal("probe not allowed")
+	}
+	b.done(slow, true)
+	b.release(slow)
+	if b.state != stateHalfOpen || b.inflight != 1 {
+		t.Fatalf("stale call changed the breaker: state %d, inflight %d", b.state, b.inflight)
+	}
+	b.done(probe, true)
+	if b.state != stateClosed {
+		t.Errorf("probe success should close the breaker, state %d", b.state)
+	}
+}
@@ -0,0 +1,52 @@
+package outbound
+
+import (
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// DefaultClientName names the client used when a config does not reference one.
+const DefaultClientName = "default"
+
+// ClientManager holds the named outbound clients declared in AppConfig.Clients.
+// Components referencing the same name share one connection pool, retry budget and breaker.
+type ClientManager struct {
+	clients map[string]*Client
+}
+
+// NewClientManager creates a client per config plus a default client with the package
+// defaults, unless a client named "default" is declared.
+func NewClientManager(confs []config.ClientConfig) *ClientManager {
+	pStat := prome.NewStat("Outbound.NewClientManager")
+	defer pStat.End()
+
+	manager := &ClientManager{clients: make(map[string]*Client, len(confs)+1)}
+	for _, conf := range confs {
+		if _, ok := manager.clients[conf.Name]; ok {
+			zlog.LOG.Warn("Outbound.NewClientManager.Duplicate", zap.String("name", conf.Name))
+			continue
+		}
+		manager.clients[conf.Name] = NewClient(conf)
+	}
+	if _, ok := manager.clients[DefaultClientName]; !ok {
+		manager.clients[DefaultClientName] = NewClient(config.ClientConfig{Name: DefaultClientName})
+	}
+	return manager
+}
+
+// Get returns the client with the given name; an empty name selects the default client.
+// Returns nil if the name is unknown or the manager is nil.
+func (m *ClientManager) Get(name string) *Client {
+	if m == nil {
+		return nil
+	}
+	if name == "" {
+		name = DefaultClientName
+	}
+	return m.clients[name]
+}
+
+// ClientManagerInstance is the global client manager, initialized before the strategy.
+var ClientManagerInstance *ClientManager
@@ -16,12 +16,18 @@ type IConstrains interface {
 
 // Constains orchestrates execution of multiple constraints:
 // 1. Weight adjustments
-// 2. Scatter-based distribution
-// 3. Fixed position inserts
+// 2. Soft scatter score decay
+// 3. Diversity re-ranking (MMR, DPP), in configuration order
+// 4. Scatter-based distribution
+// 5. Fixed position inserts
+// 6. Forced inserts
 type Constains struct {
-	scatter *Scatter               // Scatter constraint handler
-	weights []*WeightAdjust        // List of weight adjustment constraints
-	inserts []*FixedPositionInsert // List of fixed position insert constraints
+	soft        *SoftScatter           // Soft scatter constraint handler
+	scatter     *Scatter               // Scatter constraint handler
+	weights     []*WeightAdjust        // List of weight adjustment constraints
+	diversities []IConstrains          // List of diversity re-ranking constraints
+	inserts     []*FixedPositionInsert // List of fixed position insert constraints
+	forced      []*ForcedInsert        // List of forced insert constraints
 }
 
 // NewConstains constructs a Constains object from a list of constraint configurations.
@@ -30,8 +36,11 @@ func NewConstains(confs []model.IConstrain) *Constains {
 	pStat := prome.NewStat("NewConstains")
 	defer pStat.End()
 	scatters := make([]*model.ScatterBasedConstrainConfigure, 0, 8)
+	softs := make([]*model.SoftScatterConstrainConfigure, 0, 8)
 	weights := make([]*WeightAdjust, 0, 8)
 	inserts := make([]*FixedPositionInsert, 0, 8)
+	diversities := make([]IConstrains, 0, 8)
+	forced := make([]*ForcedInsert, 0, 8)
 
 	for _, conf := range confs {
 		switch conf.GetType() {
@@ -41,12 +50,36 @@ func NewConstains(confs []model.IConstrain) *Constains {
 			} else {
 				zlog.LOG.Error("NewConstains.TypeAssertError", zap.String("expected", "ScatterBasedConstrainConfigure"))
 			}
+		case model.ConstraintTypeSoftScatter:
+			if c, ok := conf.(*model.SoftScatterConstrainConfigure); ok {
+				softs = append(softs, c)
+			} else {
+				zlog.LOG.Error("NewConstains.TypeAssertError", zap.String("expected", "SoftScatterConstrainConfigure"))
+			}
+		case model.ConstraintTypeMMR:
+			if c, ok := conf.(*model.MMRConstrainConfigure); ok {
+				diversities = append(diversities, NewMMR(c))
+			} else {
+				zlog.LOG.Error("NewConstains.TypeAssertError", zap.String("expected", "MMRConstrainConfigure"))
+			}
+		case model.ConstraintTypeDPP:
+			if c, ok := conf.(*model.DPPConstrainConfigure); ok {
+				diversities = append(diversities, NewDPP(c))
+			} else {
+				zlog.LOG.Error("NewConstains.TypeAssertError", zap.String("expected", "DPPConstrainConfigure"))
+			}
 		case model.ConstraintTypeFixedPosition:
 			if c, ok := conf.(*model.FixedPositionInsertedConstrainConfigure); ok {
 				inserts = append(inserts, NewFixedPositionInsert(c))
 			} else {
 				zlog.LOG.Error("NewConstains.TypeAssertError", zap.String("expected", "FixedPositionInsertedConstrainConfigure"))
 			}
+		case model.ConstraintTypeForcedInsert:
+			if c, ok := conf.(*model.ForcedInsertConstrainConfigure); ok {
+				forced = append(forced, NewForcedInsert(c))
+			} else {
+				zlog.LOG.Error("NewConstains.TypeAssertError", zap.String("expected", "ForcedInsertConstrainConfigure"))
+			}
 		case model.ConstraintTypeWeightAdjusted:
 			if c, ok := conf.(*model.WeightAdjustedConstrainConfigure); ok {
 				weights = append(weights, NewWeightAdjust(c))
@@ -59,16 +92,28 @@ func NewConstains(confs []model.IConstrain) *Constains {
 	}
 
 	return &Constains{
-		scatter: NewScatter(scatters),
-		weights: weights,
-		inserts: inserts,
+		soft:        NewSoftScatter(softs),
+		scatter:     NewScatter(scatters),
+		weights:     weights,
+		diversities: diversities,
+		inserts:     inserts,
+		forced:      forced,
 	}
 }
 
 // Do executes constraints in a fixed sequence:
 // 1. Apply all weight adjustments
-// 2. Apply scatter-based distributions
-// 3. Apply fixed position insertions
+// 2. Apply soft scatter score decay
+// 3. Apply diversity re-ranking
+// 4. Apply scatter-based distributions
+// 5. Apply fixed position insertions
+// 6. Apply forced insertions
+//
+// Forced insertions never move the entries placed by fixed position insertions:
+// a forced item targeting a position they hold takes the next free position.
+//
+// Once the context is done, the remaining constraints are skipped
+// and the collection is returned as processed so far.
 func (c *Constains) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
 	pStat := prome.NewStat("Constains.Do")
 	defer pStat.End()
@@ -76,16 +121,83 @@ func (c *Constains) Do(uCtx *userctx.UserContext, collection model.Collection) m
 
 	// Apply weight adjustments first
 	for _, w := range c.weights {
+		if c.expired(uCtx, "weight_adjusted") {
+			pStat.MarkErr()
+			return tmp
+		}
 		tmp = w.Do(uCtx, tmp)
 	}
 
+	// Decay repeated values before the hard scatter limits
+	if c.expired(uCtx, "soft_scatter") {
+		pStat.MarkErr()
+		return tmp
+	}
+	tmp = c.soft.Do(uCtx, tmp)
+
+	// Re-rank the head for diversity
+	for _, d := range c.diversities {
+		if c.expired(uCtx, "diversity") {
+			pStat.MarkErr()
+			return tmp
+		}
+		tmp = d.Do(uCtx, tmp)
+	}
+
 	// Apply scatter distribution next
+	if c.expired(uCtx, "scatter") {
+		pStat.MarkErr()
+		return tmp
+	}
 	tmp = c.scatter.Do(uCtx, tmp)
 
-	// Apply fixed position inserts last
+	// Apply fixed position inserts next
+	fixed := make([]*model.Entry, 0, len(c.inserts))
 	for _, insert := range c.inserts {
-		tmp = insert.Do(uCtx, tmp)
+		if c.expired(uCtx, "fixed_position") {
+			pStat.MarkErr()
+			return tmp
+		}
+		var entry *model.Entry
+		tmp, entry = insert.place(uCtx, tmp)
+		if entry != nil {
+			fixed = append(fixed, entry)
+		}
+	}
+
+	// Insert items from external sources around the fixed entries
+	reserved := positions(tmp, fixed)
+	for _, insert := range c.forced {
+		if c.expired(uCtx, "forced_insert") {
+			pStat.MarkErr()
+			return tmp
+		}
+		tmp = insert.insert(uCtx, tmp, reserved)
+		reserved = positions(tmp, fixed)
 	}
 
 	return tmp
 }
+
+// positions returns the positions of the given entries in the collection.
+func positions(collection model.Collection, entries []*model.Entry) map[int]struct{} {
+	ret := make(map[int]struct{}, len(entries))
+	for i, entry := range collection {
+		for _, e := range entries {
+			if e == entry {
+				ret[i] = struct{}{}
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// expired reports whether the constraints budget is exhausted before running the given step.
+func (c *Constains) expired(uCtx *userctx.UserContext, step string) bool {
+	if err := uCtx.Err(); err != nil {
+		zlog.LOG.Warn("Constains.Do.Timeout", zap.String("skipped_step", step), zap.Error(err))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package constrains
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/uopensail/recgo-engine/internal/testlog"
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/sample"
+)
+
+// newCollection builds entries keyed "<category><n>" with a "category" feature, scores descending.
+func newCollection(keys ...string) model.Collection {
+	col := make(model.Collection, 0, len(keys))
+	for i, key := range keys {
+		entry := &model.Entry{
+			ID:       i,
+			KeyScore: model.KeyScore{Key: key, Score: float32(len(keys) - i)},
+			Runtime:  *model.NewRuntime(sample.NewImmutableFeatures(sample.NewArena())),
+		}
+		entry.Set("category", &sample.String{Value: key[:1]})
+		col = append(col, entry)
+	}
+	return col
+}
+
+func keys(col model.Collection) string {
+	ret := make([]string, 0, len(col))
+	for _, entry := range col {
+		ret = append(ret, entry.KeyScore.Key)
+	}
+	return strings.Join(ret, " ")
+}
+
+func TestScatter(t *testing.T) {
+	cases := []struct {
+		window int
+		want   string
+	}{
+		{window: 0, want: "a1 b1 c1 a2 a3 b2"},
+		{window: 2, want: "a1 b1 a2 b2 a3 c1"},
+	}
+	for _, c := range cases {
+		scatter := NewScatter([]*model.ScatterBasedConstrainConfigure{{Field: "category", Count: 1, Window: c.window}})
+		if got := keys(scatter.Do(nil, newCollection("a1", "a2", "a3", "b1", "b2", "c1"))); got != c.want {
+			t.Errorf("window %d: got %s, want %s", c.window, got, c.want)
+		}
+	}
+}
+
+func TestSoftScatter(t *testing.T) {
+	// Scores 6..1; with decay 0.5 a2 (5 -> 2.5) drops below b1 (3) but stays above c1 (1)
+	soft := NewSoftScatter([]*model.SoftScatterConstrainConfigure{{Field: "category", Decay: 0.5}})
+	ranked := soft.Do(nil, newCollection("a1", "a2", "a3", "b1", "b2", "c1"))
+	if got, want := keys(ranked), "a1 b1 a2 a3 b2 c1"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	for i := 1; i < len(ranked); i++ {
+		if ranked[i].Score > ranked[i-1].Score {
+			t.Errorf("scores not sorted at %d: %f > %f", i, ranked[i].Score, ranked[i-1].Score)
+		}
+	}
+}
+
+func TestMMR(t *testing.T) {
+	col := newCollection("a1", "a2", "b1", "c1")
+	for i, vector := range [][]float32{{1, 0}, {1, 0.05}, {0, 1}, {0.7, 0.7}} {
+		col[i].Set("emb", &sample.Float32s{Value: vector})
+	}
+
+	cases := []struct {
+		lambda float32
+		window int
+		want   string
+	}{
+		{lambda: 1, window: 0, want: "a1 a2 b1 c1"},
+		{lambda: 0.5, window: 3, want: "a1 b1 a2 c1"},
+	}
+	for _, c := range cases {
+		mmr := NewMMR(&model.MMRConstrainConfigure{Field: "emb", Lambda: c.lambda, Window: c.window})
+		if got := keys(mmr.Do(nil, append(model.Collection{}, col...))); got != c.want {
+			t.Errorf("lambda %f window %d: got %s, want %s", c.lambda, c.window, got, c.want)
+		}
+	}
+}
+
+func TestDPP(t *testing.T) {
+	col := newCollection("a1", "a2", "b1", "c1")
+	for i, vector := range [][]float32{{1, 0}, {1, 0}, {0, 1}, {0.6, 0.8}} {
+		col[i].Set("emb", &sample.Float32s{Value: vector})
+	}
+
+	cases := []struct {
+		topK int
+		want string
+	}{
+		// a2 duplicates a1 and c1 lies in the span of a1 and b1: the slate is {a1, b1}
+		{topK: 0, want: "a1 b1 a2 c1"},
+		// Only the top 2 are re-ranked, the tail is untouched
+		{topK: 2, want: "a1 a2 b1 c1"},
+	}
+	for _, c := range cases {
+		dpp := NewDPP(&model.DPPConstrainConfigure{Field: "emb", Theta: 0.5, TopK: c.topK})
+		if got := keys(dpp.Do(nil, append(model.Collection{}, col...))); got != c.want {
+			t.Errorf("top_k %d: got %s, want %s", c.topK, got, c.want)
+		}
+	}
+}
+
+// blockFilter filters the given item IDs.
+type blockFilter map[int]bool
+
+func (f blockFilter) Exists(id int) bool                 { return f[id] }
+func (f blockFilter) Exclude() []string                  { return nil }
+func (f blockFilter) Demote(collection model.Collection) {}
+
+func TestForcedInsert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.txt")
+	if err := os.WriteFile(path, []byte("a1\t{}\nb1\t{}\nx1\t{}\nx2\t{}\nx3\t{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := model.NewItems(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := res.(*model.Items)
+	blocked, _ := items.GetByKey("x2")
+
+	uCtx := &userctx.UserContext{
+		Context:  context.Background(),
+		Items:    items,
+		Filter:   blockFilter{blocked: true},
+		Features: sample.NewMutableFeatures(),
+		Versions: userctx.NewVersions(),
+	}
+	uCtx.Features.Set("u_vip", &sample.Int64{Value: 1})
+
+	// a1 is already present, "gone" is unknown and x2 is filtered: x1 and x3 are inserted
+	insert := NewForcedInsert(&model.ForcedInsertConstrainConfigure{
+		Name:      "promo",
+		Items:     []string{"a1", "gone", "x1", "x2", "x3"},
+		Positions: []int{10, 1},
+		Condition: "u_vip == 1",
+	})
+	if got, want := keys(insert.Do(uCtx, newCollection("a1", "b1", "b2"))), "a1 x1 b1 b2 x3"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// b1 holds a fixed position: x1 moves past it instead of pushing it down
+	reserved := map[int]struct{}{1: {}}
+	if got, want := keys(insert.insert(uCtx, newCollection("a1", "b1", "b2"), reserved)), "a1 b1 x1 b2 x3"; got != want {
+		t.Errorf("reserved: got %s, want %s", got, want)
+	}
+
+	uCtx.Features.Set("u_vip", &sample.Int64{Value: 0})
+	if got, want := keys(insert.Do(uCtx, newCollection("a1", "b1"))), "a1 b1"; got != want {
+		t.Errorf("condition false: got %s, want %s", got, want)
+	}
+}
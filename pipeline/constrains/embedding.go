@@ -0,0 +1,70 @@
+package constrains
+
+import (
+	"math"
+
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/ulib/sample"
+)
+
+// embeddings reads the Float32s feature field of the first n entries as L2-normalized copies.
+// Entries without the feature, with another type or with a zero vector get nil.
+func embeddings(collection model.Collection, n int, field string) [][]float32 {
+	ret := make([][]float32, n)
+	for j := 0; j < n; j++ {
+		fea, err := collection[j].Get(field)
+		if err != nil || fea.Type() != sample.Float32sType {
+			continue
+		}
+		src, _ := fea.GetFloat32s()
+		var norm float64
+		for _, x := range src {
+			norm += float64(x) * float64(x)
+		}
+		if norm == 0 {
+			continue
+		}
+		inv := float32(1 / math.Sqrt(norm))
+		vector := make([]float32, len(src))
+		for i, x := range src {
+			vector[i] = x * inv
+		}
+		ret[j] = vector
+	}
+	return ret
+}
+
+// cosine returns the cosine similarity of two normalized embeddings,
+// 0 if either is missing or their dimensions differ.
+func cosine(a, b []float32) float32 {
+	if a == nil || b == nil || len(a) != len(b) {
+		return 0
+	}
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// relevances min-max normalizes the scores of the first n entries to [0, 1].
+// Equal scores all get 1.
+func relevances(collection model.Collection, n int) []float32 {
+	ret := make([]float32, n)
+	if n == 0 {
+		return ret
+	}
+	lo, hi := collection[0].KeyScore.Score, collection[0].KeyScore.Score
+	for j := 1; j < n; j++ {
+		lo = min(lo, collection[j].KeyScore.Score)
+		hi = max(hi, collection[j].KeyScore.Score)
+	}
+	for j := 0; j < n; j++ {
+		if hi == lo {
+			ret[j] = 1
+		} else {
+			ret[j] = (collection[j].KeyScore.Score - lo) / (hi - lo)
+		}
+	}
+	return ret
+}
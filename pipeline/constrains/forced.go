@@ -0,0 +1,222 @@
+package constrains
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/program"
+	"github.com/uopensail/recgo-engine/resources"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// ForcedInsert inserts items from an external source at fixed positions, whether or not
+// they were recalled. Unlike FixedPositionInsert, which moves an entry already in the collection,
+// it builds new entries from the index, the configured item list or an operator-managed list resource.
+type ForcedInsert struct {
+	conf      *model.ForcedInsertConstrainConfigure // constraint configuration
+	condition *program.Program                      // compiled condition, nil to always insert
+	positions []int                                 // target positions in ascending order
+}
+
+// NewForcedInsert creates a forced insert constraint from configuration.
+// Panics if not exactly one source is configured, a referenced index or list is not declared,
+// no position is given or the condition cannot be parsed.
+func NewForcedInsert(conf *model.ForcedInsertConstrainConfigure) *ForcedInsert {
+	pStat := prome.NewStat("NewForcedInsert")
+	defer pStat.End()
+
+	sources := 0
+	if conf.Index != "" {
+		sources++
+		if !config.AppConfigInstance.HasIndex(conf.Index) {
+			panic(fmt.Errorf("forced insert %s: index %s is not declared", conf.Name, conf.Index))
+		}
+	}
+	if len(conf.Items) > 0 {
+		sources++
+	}
+	if conf.List != "" {
+		sources++
+		if !config.AppConfigInstance.HasList(conf.List) {
+			panic(fmt.Errorf("forced insert %s: list %s is not declared", conf.Name, conf.List))
+		}
+	}
+	if sources != 1 || len(conf.Positions) == 0 {
+		zlog.LOG.Error("NewForcedInsert.InvalidConfig",
+			zap.String("name", conf.Name),
+			zap.Int("sources", sources),
+			zap.Ints("positions", conf.Positions))
+		panic(fmt.Errorf("forced insert %s: needs exactly one source and at least one position", conf.Name))
+	}
+
+	var condition *program.Program
+	if conf.Condition != "" {
+		var err error
+		condition, err = program.NewProgram(conf.Condition)
+		if err != nil {
+			zlog.LOG.Error("NewForcedInsert program create error",
+				zap.String("name", conf.Name),
+				zap.Error(err))
+			panic(err)
+		}
+	}
+
+	positions := append([]int(nil), conf.Positions...)
+	sort.Ints(positions)
+	return &ForcedInsert{
+		conf:      conf,
+		condition: condition,
+		positions: positions,
+	}
+}
+
+// Do inserts up to one item per configured position:
+// 1. Skip the insertion unless the condition holds for the user.
+// 2. Take the source's items in order, skipping items already in the collection,
+// items unknown to Items and items excluded by the frequency filter.
+// 3. Insert the entries at their positions in ascending order; positions beyond the
+// collection append at the end.
+func (f *ForcedInsert) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
+	return f.insert(uCtx, collection, nil)
+}
+
+// insert works like Do but keeps the entries at the reserved positions in place:
+// an item whose position is reserved takes the next free position instead.
+func (f *ForcedInsert) insert(uCtx *userctx.UserContext, collection model.Collection, reserved map[int]struct{}) model.Collection {
+	pStat := prome.NewStat("ForcedInsert.Do")
+	defer pStat.End()
+
+	if f.condition != nil {
+		value, err := f.condition.Eval(uCtx.Features)
+		if err != nil {
+			pStat.MarkErr()
+			zlog.LOG.Warn("ForcedInsert.Do program eval error",
+				zap.String("name", f.conf.Name),
+				zap.Error(err))
+			return collection
+		}
+		if !program.ToBool(value) {
+			return collection
+		}
+	}
+
+	candidates := f.candidates(uCtx)
+	if len(candidates) == 0 {
+		return collection
+	}
+
+	present := make(map[string]struct{}, len(collection))
+	for _, entry := range collection {
+		present[entry.KeyScore.Key] = struct{}{}
+	}
+
+	entries := make([]*model.Entry, 0, len(f.positions))
+	for _, k := range candidates {
+		if len(entries) == len(f.positions) {
+			break
+		}
+		if _, ok := present[k.Key]; ok {
+			continue
+		}
+		id, _ := uCtx.Items.GetByKey(k.Key)
+		if id < 0 || (uCtx.Filter != nil && uCtx.Filter.Exists(id)) {
+			continue
+		}
+		entry, err := model.NewEntry(k, uCtx.Items)
+		if err != nil {
+			continue
+		}
+		entry.AddChan(f.conf.Name, "forced insert")
+		present[k.Key] = struct{}{}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return collection
+	}
+
+	// Entries at reserved positions stay there; the others flow around the inserted items
+	rest := make(model.Collection, 0, len(collection))
+	for i, entry := range collection {
+		if _, ok := reserved[i]; !ok {
+			rest = append(rest, entry)
+		}
+	}
+
+	ret := make(model.Collection, 0, len(collection)+len(entries))
+	next := 0 // next entry of rest to copy
+	// advance fills the next position with its reserved entry or the next entry of rest
+	advance := func() bool {
+		if _, ok := reserved[len(ret)]; ok {
+			ret = append(ret, collection[len(ret)])
+			return true
+		}
+		if next < len(rest) {
+			ret = append(ret, rest[next])
+			next++
+			return true
+		}
+		return false
+	}
+	for i, entry := range entries {
+		for len(ret) < f.positions[i] {
+			if !advance() {
+				break
+			}
+		}
+		for {
+			if _, ok := reserved[len(ret)]; !ok {
+				break
+			}
+			advance()
+		}
+		ret = append(ret, entry)
+	}
+	for advance() {
+	}
+
+	zlog.LOG.Debug("ForcedInsert.Completed",
+		zap.String("name", f.conf.Name),
+		zap.Int("inserted", len(entries)))
+	pStat.SetCounter(len(entries))
+	return ret
+}
+
+// candidates returns the source's items in order.
+func (f *ForcedInsert) candidates(uCtx *userctx.UserContext) []model.KeyScore {
+	switch {
+	case f.conf.Index != "":
+		index := resources.ResourceManagerInstance.GetIndex(f.conf.Index)
+		if index == nil {
+			return nil
+		}
+		uCtx.Versions.Set(f.conf.Index, index.GetURL())
+		entry, err := index.Get(f.conf.Key)
+		if err != nil {
+			return nil
+		}
+		return entry.Values
+	case f.conf.List != "":
+		list := resources.ResourceManagerInstance.GetItemList(f.conf.List)
+		if list == nil {
+			return nil
+		}
+		uCtx.Versions.Set(f.conf.List, list.GetURL())
+		return keyScores(list.GetKeys())
+	default:
+		return keyScores(f.conf.Items)
+	}
+}
+
+// keyScores wraps item keys into zero-scored KeyScores.
+func keyScores(keys []string) []model.KeyScore {
+	ret := make([]model.KeyScore, len(keys))
+	for i, key := range keys {
+		ret[i] = model.KeyScore{Key: key}
+	}
+	return ret
+}
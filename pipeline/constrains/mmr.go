@@ -0,0 +1,94 @@
+package constrains
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// MMR re-ranks the head of a collection by maximal marginal relevance over item embeddings,
+// so near-identical items are spread out even when their categorical fields differ.
+type MMR struct {
+	conf *model.MMRConstrainConfigure
+}
+
+// NewMMR creates an MMR constraint from configuration.
+// Panics if the field is empty or lambda is not in [0, 1].
+func NewMMR(conf *model.MMRConstrainConfigure) *MMR {
+	pStat := prome.NewStat("NewMMR")
+	defer pStat.End()
+	if conf.Field == "" || conf.Lambda < 0 || conf.Lambda > 1 {
+		zlog.LOG.Error("NewMMR.InvalidConfig",
+			zap.String("name", conf.Name),
+			zap.String("field", conf.Field),
+			zap.Float32("lambda", conf.Lambda))
+		panic(fmt.Errorf("mmr %s: invalid field %q or lambda %f", conf.Name, conf.Field, conf.Lambda))
+	}
+	return &MMR{
+		conf: conf,
+	}
+}
+
+// Do re-orders the top conf.Window entries (all if Window is 0) greedily:
+// each position takes the pending entry maximizing
+// lambda * relevance - (1 - lambda) * max cosine similarity to the entries placed so far.
+// Entries without embeddings are similar to nothing. Entries beyond the window keep their
+// positions, and scores are not modified.
+func (m *MMR) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
+	pStat := prome.NewStat("MMR.Do")
+	defer pStat.End()
+
+	n := len(collection)
+	if m.conf.Window > 0 {
+		n = min(n, m.conf.Window)
+	}
+	if n < 2 {
+		return collection
+	}
+
+	vectors := embeddings(collection, n, m.conf.Field)
+	relevance := relevances(collection, n)
+	lambda := m.conf.Lambda
+
+	// maxSim[j] is the highest similarity of entry j to the placed entries
+	maxSim := make([]float32, n)
+	for j := range maxSim {
+		maxSim[j] = float32(math.Inf(-1))
+	}
+	pending := make([]int, n)
+	for j := range pending {
+		pending[j] = j
+	}
+
+	ret := make(model.Collection, 0, len(collection))
+	for len(pending) > 0 {
+		pick, best := 0, float32(math.Inf(-1))
+		for p, j := range pending {
+			sim := maxSim[j]
+			if len(ret) == 0 {
+				sim = 0
+			}
+			if score := lambda*relevance[j] - (1-lambda)*sim; score > best {
+				pick, best = p, score
+			}
+		}
+
+		j := pending[pick]
+		pending = append(pending[:pick], pending[pick+1:]...)
+		ret = append(ret, collection[j])
+		for _, k := range pending {
+			maxSim[k] = max(maxSim[k], cosine(vectors[j], vectors[k]))
+		}
+	}
+	ret = append(ret, collection[n:]...)
+
+	zlog.LOG.Debug("MMR.Completed",
+		zap.Int("total_entries", len(ret)),
+		zap.Int("window", n))
+	return ret
+}
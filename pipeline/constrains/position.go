@@ -36,11 +36,17 @@ func NewFixedPositionInsert(conf *model.FixedPositionInsertedConstrainConfigure)
 // If an entry is hit (condition returns 1), it will be moved to f.conf.Position.
 // Only the first matching entry is moved; others remain in their positions.
 func (f *FixedPositionInsert) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
+	ret, _ := f.place(uCtx, collection)
+	return ret
+}
+
+// place works like Do and also returns the entry it fixed, nil if none matched.
+func (f *FixedPositionInsert) place(uCtx *userctx.UserContext, collection model.Collection) (model.Collection, *model.Entry) {
 	pStat := prome.NewStat("FixedPositionInsert.Do")
 	defer pStat.End()
 	if f.conf.Position >= len(collection) {
 		// Target position is out of range
-		return collection
+		return collection, nil
 	}
 
 	for i, entry := range collection {
@@ -60,7 +66,7 @@ func (f *FixedPositionInsert) Do(uCtx *userctx.UserContext, collection model.Col
 
 			if i == f.conf.Position {
 				// Already at desired position
-				return collection
+				return collection, entry
 			}
 
 			// Move entry to target position
@@ -74,10 +80,10 @@ func (f *FixedPositionInsert) Do(uCtx *userctx.UserContext, collection model.Col
 				}
 				ret = append(ret, e)
 			}
-			return ret
+			return ret, entry
 		}
 	}
 
 	// No matching entry found
-	return collection
+	return collection, nil
 }
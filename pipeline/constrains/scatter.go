@@ -1,19 +1,17 @@
 package constrains
 
 import (
-	"strconv"
-
 	"github.com/uopensail/recgo-engine/model"
 	"github.com/uopensail/recgo-engine/userctx"
 	"github.com/uopensail/ulib/prome"
-	"github.com/uopensail/ulib/sample"
 	"github.com/uopensail/ulib/zlog"
 	"go.uber.org/zap"
 )
 
 // Scatter enforces distribution constraints on a collection of entries.
-// Each ScatterBasedConstrainConfigure specifies a feature field and a maximum allowed count.
-// Entries exceeding the allowed count for a feature value are deferred to the end of the collection.
+// Each ScatterBasedConstrainConfigure specifies a feature field, a maximum allowed count
+// and the window the count applies to: any Window consecutive positions, or the whole list
+// when Window is zero. Each field keeps its own window.
 type Scatter struct {
 	confs []*model.ScatterBasedConstrainConfigure
 }
@@ -27,105 +25,105 @@ func NewScatter(confs []*model.ScatterBasedConstrainConfigure) *Scatter {
 	}
 }
 
-// Do applies scatter constraints:
-// 1. For each configured field, maintain a count of how many times each value appears in the accepted list.
-// 2. An entry is accepted only if none of its configured feature values exceed the count limit.
-// 3. Entries violating any constraint are moved to the end of the collection.
+// Do applies scatter constraints by filling positions greedily:
+// 1. Each position takes the first pending entry, in ranked order, that keeps every configured
+// field within its count limit; entries deferred earlier are reconsidered first, so they are
+// backfilled as soon as their window allows.
+// 2. If no pending entry fits, the constraints cannot be met and the first pending entry is taken.
+// 3. Placing an entry counts its values; with a window, values leaving the window are uncounted.
+//
+// Without windows this is the whole-list behavior: violating entries end up at the tail in ranked order.
+// Feature values are read once per entry, each position scans the pending entries.
 func (s *Scatter) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
 	pStat := prome.NewStat("Scatter.Do")
 	defer pStat.End()
-	// filter[i] holds value counts for conf[i]
-	filter := make([]map[string]int, len(s.confs))
-	for i := range s.confs {
-		filter[i] = make(map[string]int)
+	if len(s.confs) == 0 {
+		return collection
 	}
 
-	ret := make([]*model.Entry, 0, len(collection))    // accepted entries
-	remain := make([]*model.Entry, 0, len(collection)) // violating entries
-
-	for _, entry := range collection {
-		status := true // entry passes all constraints?
-
-		// Local keys slice for current entry
-		keys := make([][]string, len(s.confs))
-
-		// Check each scatter config
-		for i := 0; i < len(s.confs) && status; i++ {
-			conf := s.confs[i]
-			fea, err := entry.Get(conf.Field)
-			if err != nil {
-				// Feature not found in this entry, skip check
-				continue
+	// keys[j][i] holds the values of conf[i] for collection[j]
+	keys := make([][][]string, len(collection))
+	for j, entry := range collection {
+		keys[j] = make([][]string, len(s.confs))
+		for i, conf := range s.confs {
+			if fea, err := entry.Get(conf.Field); err == nil {
+				keys[j][i] = model.Feature2StringSlice(fea)
 			}
+		}
+	}
 
-			// Convert feature value(s) to string slice
-			keys[i] = Feature2StringSlice(fea)
+	// counts[i] holds value counts of conf[i] within its window
+	counts := make([]map[string]int, len(s.confs))
+	for i := range s.confs {
+		counts[i] = make(map[string]int)
+	}
 
-			// Check violation for any of the feature keys
-			for _, key := range keys[i] {
-				if filter[i][key] >= conf.Count {
-					status = false
-					zlog.LOG.Debug("Scatter.ConstraintViolated",
-						zap.String("entry_key", entry.KeyScore.Key),
-						zap.String("field", conf.Field),
-						zap.String("violating_value", key),
-						zap.Int("allowed_count", conf.Count),
-						zap.Int("current_count", filter[i][key]))
-					break
+	allowed := func(j int) bool {
+		for i, conf := range s.confs {
+			for _, key := range keys[j][i] {
+				if counts[i][key] >= conf.Count {
+					return false
 				}
 			}
 		}
+		return true
+	}
 
-		if status {
-			// Accept entry and update counts
-			ret = append(ret, entry)
-			for i := 0; i < len(s.confs); i++ {
-				for _, key := range keys[i] {
-					filter[i][key]++
-					zlog.LOG.Debug("Scatter.UpdateCount",
-						zap.String("field", s.confs[i].Field),
-						zap.String("value", key),
-						zap.Int("new_count", filter[i][key]))
+	pending := make([]int, len(collection)) // indexes of entries not placed yet, in ranked order
+	for j := range pending {
+		pending[j] = j
+	}
+	placed := make([]int, 0, len(collection)) // index of the entry at each position
+	deferred := 0
+
+	for pos := 0; pos < len(collection); pos++ {
+		// Uncount the entries leaving each window
+		for i, conf := range s.confs {
+			if conf.Window > 0 && pos >= conf.Window {
+				for _, key := range keys[placed[pos-conf.Window]][i] {
+					counts[i][key]--
 				}
 			}
-		} else {
-			// Violating entry goes to remain list
-			remain = append(remain, entry)
+		}
+
+		pick := 0
+		for p, j := range pending {
+			if allowed(j) {
+				pick = p
+				break
+			}
+			if p == len(pending)-1 {
+				// Nothing fits, keep ranked order
+				deferred++
+				zlog.LOG.Debug("Scatter.ConstraintRelaxed",
+					zap.String("entry_key", collection[pending[0]].KeyScore.Key),
+					zap.Int("position", pos))
+			}
+		}
+
+		j := pending[pick]
+		pending = append(pending[:pick], pending[pick+1:]...)
+		placed = append(placed, j)
+		for i := range s.confs {
+			for _, key := range keys[j][i] {
+				counts[i][key]++
+			}
 		}
 	}
 
-	// Append violating entries at the end
-	ret = append(ret, remain...)
+	ret := make(model.Collection, len(collection))
+	moved := 0
+	for pos, j := range placed {
+		ret[pos] = collection[j]
+		if pos != j {
+			moved++
+		}
+	}
 
 	zlog.LOG.Debug("Scatter.Completed",
 		zap.Int("total_entries", len(collection)),
-		zap.Int("accepted_entries", len(ret)-len(remain)),
-		zap.Int("violated_entries", len(remain)))
+		zap.Int("moved_entries", moved),
+		zap.Int("violated_entries", deferred))
 
 	return ret
 }
-
-// Feature2StringSlice converts a sample.Feature value into a slice of strings.
-// This is required because scatter constraints are applied on string keys.
-func Feature2StringSlice(feature sample.Feature) []string {
-	switch feature.Type() {
-	case sample.Int64Type:
-		val, _ := feature.GetInt64()
-		return []string{strconv.FormatInt(val, 10)}
-	case sample.Int64sType:
-		val, _ := feature.GetInt64s()
-		ret := make([]string, 0, len(val))
-		for _, v := range val {
-			ret = append(ret, strconv.FormatInt(v, 10))
-		}
-		return ret
-	case sample.StringType:
-		val, _ := feature.GetString()
-		return []string{val}
-	case sample.StringsType:
-		val, _ := feature.GetStrings()
-		return val
-	default:
-		return nil
-	}
-}
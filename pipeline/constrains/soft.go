@@ -0,0 +1,122 @@
+package constrains
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// SoftScatter diversifies a collection by decaying scores instead of deferring entries:
+// an entry sharing a value with k entries already placed above it scores score * decay^k,
+// so a strong entry moves down a few positions rather than to the tail.
+// Several configurations combine multiplicatively.
+type SoftScatter struct {
+	confs []*model.SoftScatterConstrainConfigure
+}
+
+// NewSoftScatter creates a soft scatter constraint from configurations.
+// Panics if a decay is not in (0, 1].
+func NewSoftScatter(confs []*model.SoftScatterConstrainConfigure) *SoftScatter {
+	pStat := prome.NewStat("NewSoftScatter")
+	defer pStat.End()
+	for _, conf := range confs {
+		if conf.Decay <= 0 || conf.Decay > 1 {
+			zlog.LOG.Error("NewSoftScatter.InvalidDecay",
+				zap.String("name", conf.Name),
+				zap.Float32("decay", conf.Decay))
+			panic(fmt.Errorf("soft scatter %s: decay %f not in (0, 1]", conf.Name, conf.Decay))
+		}
+	}
+	return &SoftScatter{
+		confs: confs,
+	}
+}
+
+// Do re-sorts the collection greedily, position by position:
+// 1. Every pending entry's effective score is its score times decay^k per configuration,
+// k being the highest count among its values in the entries placed so far.
+// 2. The entry with the highest effective score takes the position (ties keep ranked order),
+// its score is replaced by the effective score and its values are counted.
+//
+// Effective scores only decrease, so the returned collection stays sorted by score.
+// Negative scores are divided by decay^k instead, so decay always lowers the score.
+func (s *SoftScatter) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
+	pStat := prome.NewStat("SoftScatter.Do")
+	defer pStat.End()
+	if len(s.confs) == 0 || len(collection) == 0 {
+		return collection
+	}
+
+	// keys[j][i] holds the values of conf[i] for collection[j]
+	keys := make([][][]string, len(collection))
+	for j, entry := range collection {
+		keys[j] = make([][]string, len(s.confs))
+		for i, conf := range s.confs {
+			if fea, err := entry.Get(conf.Field); err == nil {
+				keys[j][i] = model.Feature2StringSlice(fea)
+			}
+		}
+	}
+
+	counts := make([]map[string]int, len(s.confs))
+	for i := range s.confs {
+		counts[i] = make(map[string]int)
+	}
+
+	effective := func(j int) float32 {
+		factor := 1.0
+		for i, conf := range s.confs {
+			k := 0
+			for _, key := range keys[j][i] {
+				k = max(k, counts[i][key])
+			}
+			if k > 0 {
+				factor *= math.Pow(float64(conf.Decay), float64(k))
+			}
+		}
+		score := float64(collection[j].KeyScore.Score)
+		if score < 0 {
+			return float32(score / factor)
+		}
+		return float32(score * factor)
+	}
+
+	pending := make([]int, len(collection))
+	for j := range pending {
+		pending[j] = j
+	}
+	ret := make(model.Collection, 0, len(collection))
+	for len(pending) > 0 {
+		pick, best := 0, effective(pending[0])
+		for p := 1; p < len(pending); p++ {
+			if score := effective(pending[p]); score > best {
+				pick, best = p, score
+			}
+		}
+
+		j := pending[pick]
+		pending = append(pending[:pick], pending[pick+1:]...)
+		entry := collection[j]
+		if entry.KeyScore.Score != best {
+			zlog.LOG.Debug("SoftScatter.Decayed",
+				zap.String("entry_key", entry.KeyScore.Key),
+				zap.Float32("score", entry.KeyScore.Score),
+				zap.Float32("decayed_score", best))
+		}
+		entry.KeyScore.Score = best
+		ret = append(ret, entry)
+		for i := range s.confs {
+			for _, key := range keys[j][i] {
+				counts[i][key]++
+			}
+		}
+	}
+
+	zlog.LOG.Debug("SoftScatter.Completed", zap.Int("total_entries", len(ret)))
+	return ret
+}
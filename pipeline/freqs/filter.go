@@ -1,11 +1,40 @@
 package freqs
 
+import (
+	"sort"
+
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/ulib/sample"
+)
+
+// Penalty is the score penalty of demote rules: the demoted score is score * Factor + Offset.
+type Penalty struct {
+	Factor float32
+	Offset float32
+}
+
+// NewPenalty returns the neutral penalty.
+func NewPenalty() Penalty {
+	return Penalty{Factor: 1}
+}
+
+// Combine stacks another penalty on top of this one.
+func (p Penalty) Combine(p1 Penalty) Penalty {
+	return Penalty{Factor: p.Factor * p1.Factor, Offset: p.Offset + p1.Offset}
+}
+
 // Filter stores a unique set of IDs with their corresponding keys.
 // It maintains insertion order for both IDs and keys.
+// It also holds capped attribute values: any item whose feature carries a capped value is filtered,
+// and the penalties of demote rules, keyed by item ID or by attribute value.
 type Filter struct {
-	dict map[int]struct{} // set of IDs for fast existence checking
-	ids  []int            // ordered list of IDs
-	keys []string         // ordered list of keys corresponding to IDs
+	dict          map[int]struct{}               // set of IDs for fast existence checking
+	ids           []int                          // ordered list of IDs
+	keys          []string                       // ordered list of keys corresponding to IDs
+	items         *model.Items                   // items used to resolve attribute values of candidates
+	attrs         map[string]map[string]struct{} // field -> capped values
+	penalties     map[int]Penalty                // item ID -> penalty
+	attrPenalties map[string]map[string]Penalty  // field -> value -> penalty
 }
 
 // NewFilter creates and returns a new Filter with pre-allocated capacity.
@@ -23,6 +52,19 @@ func (f *Filter) Merge(f1 *Filter) {
 	for i := range f1.ids {
 		f.Add(f1.keys[i], f1.ids[i])
 	}
+	for field, values := range f1.attrs {
+		for value := range values {
+			f.AddAttr(f1.items, field, value)
+		}
+	}
+	for id, penalty := range f1.penalties {
+		f.AddPenalty(id, penalty)
+	}
+	for field, values := range f1.attrPenalties {
+		for value, penalty := range values {
+			f.AddAttrPenalty(f1.items, field, value, penalty)
+		}
+	}
 }
 
 // Add inserts a new (key, id) pair if the ID is not already present.
@@ -36,10 +78,138 @@ func (f *Filter) Add(key string, id int) {
 	f.keys = append(f.keys, key)
 }
 
-// Exists checks whether the given ID exists in the Filter.
+// AddAttr caps a value of the given item feature field.
+// items is used to resolve the field of candidates checked by Exists.
+func (f *Filter) AddAttr(items *model.Items, field string, value string) {
+	if f.attrs == nil {
+		f.attrs = make(map[string]map[string]struct{}, 4)
+	}
+	values, ok := f.attrs[field]
+	if !ok {
+		values = make(map[string]struct{}, 8)
+		f.attrs[field] = values
+	}
+	values[value] = struct{}{}
+	f.items = items
+}
+
+// AddPenalty stacks a penalty on the item with the given ID.
+func (f *Filter) AddPenalty(id int, penalty Penalty) {
+	if f.penalties == nil {
+		f.penalties = make(map[int]Penalty, 64)
+	}
+	if p, ok := f.penalties[id]; ok {
+		penalty = p.Combine(penalty)
+	}
+	f.penalties[id] = penalty
+}
+
+// AddAttrPenalty stacks a penalty on every item whose feature field carries the given value.
+func (f *Filter) AddAttrPenalty(items *model.Items, field string, value string, penalty Penalty) {
+	if f.attrPenalties == nil {
+		f.attrPenalties = make(map[string]map[string]Penalty, 4)
+	}
+	values, ok := f.attrPenalties[field]
+	if !ok {
+		values = make(map[string]Penalty, 8)
+		f.attrPenalties[field] = values
+	}
+	if p, ok := values[value]; ok {
+		penalty = p.Combine(penalty)
+	}
+	values[value] = penalty
+	f.items = items
+}
+
+// Demote sets the combined penalty of every entry as the FreqFactorKey and FreqOffsetKey
+// runtime features. Entries without penalty get the neutral factor 1 and offset 0,
+// so rank and constraint expressions can always reference them.
+func (f *Filter) Demote(collection model.Collection) {
+	for _, entry := range collection {
+		penalty := f.penalty(entry)
+		entry.Set(model.FreqFactorKey, &sample.Float32{Value: penalty.Factor})
+		entry.Set(model.FreqOffsetKey, &sample.Float32{Value: penalty.Offset})
+	}
+}
+
+// ApplyPenalties rescales the score of every demoted entry to score * factor + offset,
+// reading the FreqFactorKey and FreqOffsetKey runtime features set by Demote,
+// and re-sorts the collection (stable) if any score changed.
+// Negative scores (raw model margins, rule expressions) are divided by the factor instead,
+// so a factor below 1 always lowers the score.
+func ApplyPenalties(collection model.Collection) model.Collection {
+	demoted := 0
+	for _, entry := range collection {
+		factor, offset := float32(1), float32(0)
+		if fea := entry.Runtime.RunTime.Get(model.FreqFactorKey); fea != nil {
+			factor, _ = fea.GetFloat32()
+		}
+		if fea := entry.Runtime.RunTime.Get(model.FreqOffsetKey); fea != nil {
+			offset, _ = fea.GetFloat32()
+		}
+		if factor == 1 && offset == 0 {
+			continue
+		}
+		entry.KeyScore.Score = penalize(entry.KeyScore.Score, factor) + offset
+		demoted++
+	}
+
+	if demoted > 0 {
+		sort.Stable(collection)
+	}
+	return collection
+}
+
+// penalize applies a multiplicative penalty factor to a score of either sign.
+func penalize(score float32, factor float32) float32 {
+	if score < 0 {
+		return score / factor
+	}
+	return score * factor
+}
+
+// penalty combines the ID penalty and the attribute penalties of an entry.
+func (f *Filter) penalty(entry *model.Entry) Penalty {
+	penalty := NewPenalty()
+	if p, ok := f.penalties[entry.ID]; ok {
+		penalty = penalty.Combine(p)
+	}
+	for field, values := range f.attrPenalties {
+		for _, value := range model.Feature2StringSlice(entry.Runtime.Basic.Get(field)) {
+			if p, ok := values[value]; ok {
+				penalty = penalty.Combine(p)
+			}
+		}
+	}
+	return penalty
+}
+
+// Exists checks whether the given ID exists in the Filter,
+// or whether the item carries a capped attribute value.
 func (f *Filter) Exists(id int) bool {
-	_, exists := f.dict[id]
-	return exists
+	if _, exists := f.dict[id]; exists {
+		return true
+	}
+	return f.capped(id)
+}
+
+// capped checks whether any configured feature of the item carries a capped value.
+func (f *Filter) capped(id int) bool {
+	if len(f.attrs) == 0 || f.items == nil {
+		return false
+	}
+	feas := f.items.GetByID(id)
+	if feas == nil {
+		return false
+	}
+	for field, values := range f.attrs {
+		for _, value := range model.Feature2StringSlice(feas.Get(field)) {
+			if _, ok := values[value]; ok {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // Missing checks whether the given ID is not present in the Filter.
@@ -49,6 +219,7 @@ func (f *Filter) Missing(id int) bool {
 }
 
 // Exclude returns all stored keys in the order they were added.
+// Items filtered by capped attribute values are not listed.
 func (f *Filter) Exclude() []string {
 	return f.keys
 }
@@ -0,0 +1,83 @@
+package freqs
+
+import (
+	"testing"
+
+	_ "github.com/uopensail/recgo-engine/internal/testlog"
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/ulib/sample"
+)
+
+func TestPenaltyOf(t *testing.T) {
+	multiply := model.FreqConfigure{Frequency: 2, Mode: model.FreqModeDemote, Penalty: 0.5, Scale: true}
+	if p := penaltyOf(multiply, 4); p.Factor != 0.125 || p.Offset != 0 {
+		t.Errorf("scaled multiply: got %+v", p)
+	}
+	multiply.Scale = false
+	if p := penaltyOf(multiply, 4); p.Factor != 0.5 {
+		t.Errorf("multiply: got %+v", p)
+	}
+
+	add := model.FreqConfigure{Frequency: 2, Mode: model.FreqModeDemote, PenaltyType: model.PenaltyTypeAdd, Penalty: 0.1, Scale: true}
+	if p := penaltyOf(add, 3); p.Factor != 1 || p.Offset > -0.199 || p.Offset < -0.201 {
+		t.Errorf("scaled add: got %+v", p)
+	}
+
+	missing := model.FreqConfigure{Name: "missing", Frequency: 2, Mode: model.FreqModeDemote}
+	if err := validate(missing); err == nil {
+		t.Errorf("multiply rule without penalty should be rejected, got factor %f", penaltyOf(missing, 2).Factor)
+	}
+	for _, rule := range []model.FreqConfigure{
+		{Name: "boost", Frequency: 2, Mode: model.FreqModeDemote, Penalty: 1.5},
+		{Name: "zero add", Frequency: 2, Mode: model.FreqModeDemote, PenaltyType: model.PenaltyTypeAdd},
+		{Name: "negative add", Frequency: 2, Mode: model.FreqModeDemote, PenaltyType: model.PenaltyTypeAdd, Penalty: -0.1},
+	} {
+		if err := validate(rule); err == nil {
+			t.Errorf("%s: should be rejected", rule.Name)
+		}
+	}
+	for _, rule := range []model.FreqConfigure{multiply, add, {Name: "filter", Frequency: 2}} {
+		if err := validate(rule); err != nil {
+			t.Errorf("%s: unexpected error %v", rule.Name, err)
+		}
+	}
+}
+
+func TestDemote(t *testing.T) {
+	collection := make(model.Collection, 0, 3)
+	for i, score := range []float32{0.9, 0.8, 0.7} {
+		collection = append(collection, &model.Entry{
+			ID:       i,
+			KeyScore: model.KeyScore{Key: string(rune('a' + i)), Score: score},
+			Runtime:  *model.NewRuntime(sample.NewImmutableFeatures(sample.NewArena())),
+		})
+	}
+
+	filter := NewFilter()
+	filter.AddPenalty(0, Penalty{Factor: 0.5})
+	filter.AddPenalty(0, Penalty{Factor: 1, Offset: -0.1})
+	filter.Demote(collection)
+
+	ranked := ApplyPenalties(collection)
+	if ranked[0].ID != 1 || ranked[1].ID != 2 || ranked[2].ID != 0 {
+		t.Fatalf("unexpected order: %d %d %d", ranked[0].ID, ranked[1].ID, ranked[2].ID)
+	}
+	if score := ranked[2].Score; score < 0.349 || score > 0.351 {
+		t.Errorf("demoted score: got %f, want 0.35", score)
+	}
+	if fea, _ := ranked[0].Get(model.FreqFactorKey); fea == nil || fea.GetFloat32Unsafe() != 1 {
+		t.Errorf("undemoted entry should carry factor 1")
+	}
+
+	// Negative scores are lowered too
+	for _, entry := range collection {
+		entry.Score = []float32{-1, -1.5, 0.7}[entry.ID]
+	}
+	filter = NewFilter()
+	filter.AddPenalty(0, Penalty{Factor: 0.5})
+	filter.Demote(collection)
+	ranked = ApplyPenalties(collection)
+	if last := ranked[len(ranked)-1]; last.ID != 0 || last.Score != -2 {
+		t.Errorf("demoted negative score: got %d with %f, want 0 with -2", last.ID, last.Score)
+	}
+}
@@ -1,7 +1,9 @@
 package freqs
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"time"
 
@@ -17,44 +19,6 @@ type IFilter interface {
 	Do(userCtx *userctx.UserContext) model.IFilter
 }
 
-const (
-	// userRecordKeyPrefix is the Redis (or feature storage) key prefix
-	// for storing user action records.
-	//
-	// The full key format before splitting is:
-	//   u_r_${action} -> "timestamp|itemKey"
-	//
-	// Example:
-	//   u_r_click -> "1670832000|item123"
-	//
-	// This original string contains both a UNIX timestamp and an item ID/key
-	// separated by actionSeparator ("|").
-	//
-	// During processing, this data is split into two separate fields:
-	//   u_r_${action}_ts  -> stores only timestamps (int64 array)
-	//   u_r_${action}_ids -> stores only item keys (string array)
-	//
-	// The splitting allows independent access to timestamps and item IDs,
-	// which improves processing speed and simplifies filtering logic.
-	userRecordKeyPrefix = "u_r_%s"
-
-	// idsKeySuffix is the suffix appended to the base action key
-	// to store only item IDs/keys after data splitting.
-	// Example: "u_r_click_ids"
-	idsKeySuffix = "_ids"
-
-	// timestampKeySuffix is the suffix appended to the base action key
-	// to store only timestamps after data splitting.
-	// Example: "u_r_click_ts"
-	timestampKeySuffix = "_ts"
-
-	// actionSeparator defines the delimiter between timestamp and itemKey
-	// in the original combined data format.
-	// Example original data: "1670832000|item123"
-	// After splitting by actionSeparator: ["1670832000", "item123"]
-	actionSeparator = "|"
-)
-
 // FreqController controls frequency-based filtering logic.
 type FreqController struct {
 	frequencies   []model.IFreq // List of frequency rules
@@ -62,9 +26,18 @@ type FreqController struct {
 }
 
 // NewFreqController creates a new frequency controller and enables all frequencies by default.
+// Panics if a rule has an unknown mode or penalty type, or a demote rule has a penalty
+// outside (0, 1] for multiply or not positive for add.
 func NewFreqController(frequencies []model.IFreq) *FreqController {
 	pStat := prome.NewStat("NewFreqController")
 	defer pStat.End()
+
+	for _, frequency := range frequencies {
+		if err := validate(frequency); err != nil {
+			zlog.LOG.Error("NewFreqController.InvalidRule", zap.String("name", frequency.GetName()), zap.Error(err))
+			panic(err)
+		}
+	}
 	controller := &FreqController{
 		frequencies:   frequencies,
 		enabledStatus: make([]bool, len(frequencies)),
@@ -120,35 +93,73 @@ func (fc *FreqController) Do(userCtx *userctx.UserContext) model.IFilter {
 
 // processFrequency processes a single frequency rule and returns a Filter.
 func (fc *FreqController) processFrequency(userCtx *userctx.UserContext, frequency model.IFreq) *Filter {
-	actionKey := fmt.Sprintf(userRecordKeyPrefix, frequency.GetAction())
-
-	// Fetch raw feature data
-	rawKeys := userCtx.Features.Get(actionKey + idsKeySuffix)
-	rawTimestamps := userCtx.Features.Get(actionKey + timestampKeySuffix)
-
-	if rawKeys == nil || rawTimestamps == nil {
-		zlog.LOG.Error("FreqController.ProcessFrequency.NoData", zap.String("actionKey", actionKey))
+	// Fetch action records from user features
+	itemKeys, timestamps, err := userCtx.GetActionRecords(frequency.GetAction())
+	if errors.Is(err, userctx.ErrNoActionRecords) {
+		zlog.LOG.Error("FreqController.ProcessFrequency.NoData", zap.String("actionKey", userctx.ActionKey(frequency.GetAction())))
+		return NewFilter()
+	} else if err != nil {
+		zlog.LOG.Error("FreqController.ProcessFrequency.DataError", zap.Error(err))
 		return NewFilter()
 	}
 
-	itemKeys, err1 := rawKeys.GetStrings()
-	timestamps, err2 := rawTimestamps.GetInt64s()
-
-	if err1 != nil || err2 != nil || len(itemKeys) != len(timestamps) {
-		zlog.LOG.Error("FreqController.ProcessFrequency.DataError",
-			zap.Error(err1),
-			zap.Error(err2),
-			zap.Int("keys_len", len(itemKeys)),
-			zap.Int("timestamps_len", len(timestamps)),
-		)
-		return NewFilter()
+	// Attribute rules count the values of the item feature instead of the item keys
+	if field := frequency.GetField(); field != "" {
+		frequencyMap := fc.calculateAttrFrequency(userCtx, itemKeys, timestamps, frequency.GetTimespan(), field)
+		return fc.createAttrFilter(userCtx, frequencyMap, frequency)
 	}
 
 	// Calculate frequencies
 	frequencyMap := fc.calculateFrequency(itemKeys, timestamps, frequency.GetTimespan())
 
 	// Create and return filter
-	return fc.createFilter(userCtx, frequencyMap, frequency.GetFrequency())
+	return fc.createFilter(userCtx, frequencyMap, frequency)
+}
+
+// validate checks the mode and penalty settings of a frequency rule.
+// A multiply penalty must be in (0, 1] and an add penalty must be positive,
+// so a demote rule never boosts an item nor zeroes its score.
+func validate(frequency model.IFreq) error {
+	switch frequency.GetMode() {
+	case model.FreqModeFilter:
+		return nil
+	case model.FreqModeDemote:
+	default:
+		return fmt.Errorf("freq %s: unknown mode %s", frequency.GetName(), frequency.GetMode())
+	}
+
+	penalty := frequency.GetPenalty()
+	switch frequency.GetPenaltyType() {
+	case model.PenaltyTypeMultiply:
+		if penalty <= 0 || penalty > 1 {
+			return fmt.Errorf("freq %s: multiply penalty %f not in (0, 1]", frequency.GetName(), penalty)
+		}
+	case model.PenaltyTypeAdd:
+		if penalty <= 0 {
+			return fmt.Errorf("freq %s: add penalty %f not positive", frequency.GetName(), penalty)
+		}
+	default:
+		return fmt.Errorf("freq %s: unknown penalty type %s", frequency.GetName(), frequency.GetPenaltyType())
+	}
+	return nil
+}
+
+// penaltyOf returns the penalty of a demote rule for an item or value counted count times.
+// Scaled rules apply the penalty once per action at or over the threshold.
+func penaltyOf(frequency model.IFreq, count int) Penalty {
+	times := 1
+	if frequency.GetScale() {
+		times = count - frequency.GetFrequency() + 1
+	}
+
+	penalty := NewPenalty()
+	switch frequency.GetPenaltyType() {
+	case model.PenaltyTypeAdd:
+		penalty.Offset = -frequency.GetPenalty() * float32(times)
+	default:
+		penalty.Factor = float32(math.Pow(float64(frequency.GetPenalty()), float64(times)))
+	}
+	return penalty
 }
 
 // calculateFrequency counts occurrences of itemKeys within the given timespan.
@@ -168,16 +179,75 @@ func (fc *FreqController) calculateFrequency(itemKeys []string, timestamps []int
 	return frequencyMap
 }
 
-// createFilter creates a Filter from frequency data based on a threshold.
-func (fc *FreqController) createFilter(userCtx *userctx.UserContext, frequencyMap map[string]int, threshold int) *Filter {
+// calculateAttrFrequency counts occurrences of the values of the item feature field
+// over the records within the given timespan. Items are resolved through Items.GetByKey;
+// records of unknown items or items without the field are skipped.
+func (fc *FreqController) calculateAttrFrequency(userCtx *userctx.UserContext, itemKeys []string, timestamps []int64, timespan int, field string) map[string]int {
+	frequencyMap := make(map[string]int)
+	cutoffTime := time.Now().Unix() - int64(timespan)
+
+	for i, timestamp := range timestamps {
+		if timestamp < cutoffTime {
+			continue
+		}
+		_, feas := userCtx.Items.GetByKey(itemKeys[i])
+		if feas == nil {
+			continue
+		}
+		for _, value := range model.Feature2StringSlice(feas.Get(field)) {
+			frequencyMap[value]++
+		}
+	}
+
+	zlog.LOG.Debug("FreqController.CalculateAttrFrequency.Result",
+		zap.String("field", field),
+		zap.Int("unique_values", len(frequencyMap)))
+	return frequencyMap
+}
+
+// createAttrFilter creates a Filter capping (or demoting) every value of the rule's field
+// whose count reaches the threshold.
+func (fc *FreqController) createAttrFilter(userCtx *userctx.UserContext, frequencyMap map[string]int, frequency model.IFreq) *Filter {
+	filter := NewFilter()
+	field := frequency.GetField()
+	demote := frequency.GetMode() == model.FreqModeDemote
+
+	capped := 0
+	for value, count := range frequencyMap {
+		if count < frequency.GetFrequency() {
+			continue
+		}
+		if demote {
+			filter.AddAttrPenalty(userCtx.Items, field, value, penaltyOf(frequency, count))
+		} else {
+			filter.AddAttr(userCtx.Items, field, value)
+		}
+		capped++
+	}
+
+	zlog.LOG.Debug("FreqController.CreateAttrFilter.Completed",
+		zap.String("field", field),
+		zap.Int("capped_values", capped))
+	return filter
+}
+
+// createFilter creates a Filter from frequency data based on the rule's threshold.
+// Demote rules attach penalties to the items instead of filtering them.
+func (fc *FreqController) createFilter(userCtx *userctx.UserContext, frequencyMap map[string]int, frequency model.IFreq) *Filter {
 	filter := NewFilter()
+	demote := frequency.GetMode() == model.FreqModeDemote
 
 	for itemKey, count := range frequencyMap {
-		if count >= threshold {
-			if itemID, _ := userCtx.Items.GetByKey(itemKey); itemID >= 0 {
-				filter.Add(itemKey, itemID)
-			} else {
+		if count >= frequency.GetFrequency() {
+			itemID, _ := userCtx.Items.GetByKey(itemKey)
+			if itemID < 0 {
 				zlog.LOG.Error("FreqController.CreateFilter.InvalidItem", zap.String("itemKey", itemKey))
+				continue
+			}
+			if demote {
+				filter.AddPenalty(itemID, penaltyOf(frequency, count))
+			} else {
+				filter.Add(itemKey, itemID)
 			}
 		}
 	}
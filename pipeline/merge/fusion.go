@@ -0,0 +1,149 @@
+package merge
+
+import (
+	"sort"
+
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultRRFK is the default rank constant of reciprocal rank fusion.
+	defaultRRFK = 60
+)
+
+// fuse merges collections by a per-entry fused score.
+// contribution returns the score an entry at position rank of the collection of recall c adds.
+// Entries are ordered by fused score in descending order; ties keep round-robin order,
+// which makes the result reproducible. The fused score replaces Entry.Score.
+func fuse(collections []model.Collection, limit int,
+	contribution func(c int, rank int, entry *model.Entry) float32) model.Collection {
+	maxSize := maxLen(collections)
+	d := newDedup(maxSize * len(collections))
+	scores := make(map[int]float32, maxSize*len(collections))
+
+	// Scores are computed before dedup merges, since dedup keeps only the first entry
+	for c, col := range collections {
+		for rank, entry := range col {
+			scores[entry.ID] += contribution(c, rank, entry)
+		}
+	}
+
+	for i := 0; i < maxSize; i++ {
+		for _, col := range collections {
+			if len(col) > i {
+				d.add(col[i])
+			}
+		}
+	}
+
+	for _, entry := range d.ret {
+		entry.KeyScore.Score = scores[entry.ID]
+	}
+	sort.Stable(d.ret)
+
+	if len(d.ret) > limit {
+		d.ret = d.ret[:limit]
+	}
+	return d.ret
+}
+
+// weight returns the channel weight of the named recall, defaulting to 1.
+func weight(conf *model.MergeConfigure, name string) float32 {
+	if w, ok := conf.Weights[name]; ok {
+		return w
+	}
+	return 1
+}
+
+// Weighted merges recall outputs by weighted score fusion:
+// each recall's scores are min-max normalized to [0, 1], multiplied by the channel weight,
+// and summed across the recalls that returned the item.
+type Weighted struct {
+	conf *model.MergeConfigure // merge configuration
+}
+
+// NewWeighted creates a new Weighted merger.
+func NewWeighted(conf *model.MergeConfigure) *Weighted {
+	pStat := prome.NewStat("Merge.NewWeighted")
+	defer pStat.End()
+	return &Weighted{
+		conf: conf,
+	}
+}
+
+// Do fuses normalized recall scores and returns the top conf.Limit entries.
+func (w *Weighted) Do(uCtx *userctx.UserContext, names []string, collections []model.Collection) model.Collection {
+	pStat := prome.NewStat("Merge.Weighted.Do")
+	defer pStat.End()
+
+	// Score range of each recall
+	mins := make([]float32, len(collections))
+	spans := make([]float32, len(collections))
+	for c, col := range collections {
+		if len(col) == 0 {
+			continue
+		}
+		lo, hi := col[0].Score, col[0].Score
+		for _, entry := range col {
+			if entry.Score < lo {
+				lo = entry.Score
+			}
+			if entry.Score > hi {
+				hi = entry.Score
+			}
+		}
+		mins[c], spans[c] = lo, hi-lo
+	}
+
+	ret := fuse(collections, w.conf.Limit, func(c int, rank int, entry *model.Entry) float32 {
+		norm := float32(1)
+		if spans[c] > 0 {
+			norm = (entry.Score - mins[c]) / spans[c]
+		}
+		return weight(w.conf, names[c]) * norm
+	})
+
+	zlog.LOG.Debug("Merge.Weighted.Do.Completed",
+		zap.Int("merged_count", len(ret)),
+		zap.Int("limit", w.conf.Limit))
+	pStat.SetCounter(len(ret))
+	return ret
+}
+
+// RRF merges recall outputs by reciprocal rank fusion:
+// an item at 0-based position r of a recall contributes weight / (K + r + 1).
+type RRF struct {
+	conf *model.MergeConfigure // merge configuration
+}
+
+// NewRRF creates a new RRF merger. K defaults to 60 when not configured.
+func NewRRF(conf *model.MergeConfigure) *RRF {
+	pStat := prome.NewStat("Merge.NewRRF")
+	defer pStat.End()
+	if conf.K <= 0 {
+		conf.K = defaultRRFK
+	}
+	return &RRF{
+		conf: conf,
+	}
+}
+
+// Do fuses recall ranks and returns the top conf.Limit entries.
+func (r *RRF) Do(uCtx *userctx.UserContext, names []string, collections []model.Collection) model.Collection {
+	pStat := prome.NewStat("Merge.RRF.Do")
+	defer pStat.End()
+
+	ret := fuse(collections, r.conf.Limit, func(c int, rank int, entry *model.Entry) float32 {
+		return weight(r.conf, names[c]) / (r.conf.K + float32(rank) + 1)
+	})
+
+	zlog.LOG.Debug("Merge.RRF.Do.Completed",
+		zap.Int("merged_count", len(ret)),
+		zap.Int("limit", r.conf.Limit))
+	pStat.SetCounter(len(ret))
+	return ret
+}
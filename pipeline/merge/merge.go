@@ -0,0 +1,84 @@
+package merge
+
+import (
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// IMerge defines the interface for merging recall outputs.
+// names and collections are aligned and follow the configured recall order;
+// a nil collection means the recall returned nothing.
+type IMerge interface {
+	Do(uCtx *userctx.UserContext, names []string, collections []model.Collection) model.Collection
+}
+
+// NewMerge creates a specific IMerge implementation based on the given configuration type.
+// Supported types:
+// - model.MergeTypeRoundRobin (default) -> RoundRobin merger
+// - model.MergeTypeQuota                -> Quota merger
+// - model.MergeTypeWeighted             -> Weighted score fusion
+// - model.MergeTypeRRF                  -> Reciprocal rank fusion
+func NewMerge(conf *model.MergeConfigure) IMerge {
+	switch conf.Type {
+	case "", model.MergeTypeRoundRobin:
+		return NewRoundRobin(conf)
+	case model.MergeTypeQuota:
+		return NewQuota(conf)
+	case model.MergeTypeWeighted:
+		return NewWeighted(conf)
+	case model.MergeTypeRRF:
+		return NewRRF(conf)
+	default:
+		zlog.LOG.Warn("NewMerge.UnknownType", zap.String("type", conf.Type))
+	}
+	return nil
+}
+
+// dedup tracks merged entries by item ID.
+// Entries recalled by several channels are kept once, with their channels merged.
+type dedup struct {
+	first map[int]*model.Entry
+	ret   model.Collection
+}
+
+// newDedup creates a dedup with the given capacity.
+func newDedup(capacity int) *dedup {
+	return &dedup{
+		first: make(map[int]*model.Entry, capacity),
+		ret:   make(model.Collection, 0, capacity),
+	}
+}
+
+// add appends entry if its ID has not been seen, otherwise merges its channels into the first one.
+// Returns true if the entry was appended.
+func (d *dedup) add(entry *model.Entry) bool {
+	if d.merge(entry) {
+		return false
+	}
+	d.first[entry.ID] = entry
+	d.ret = append(d.ret, entry)
+	return true
+}
+
+// merge merges the channels of entry into the first entry with its ID.
+// Returns false if the ID has not been seen.
+func (d *dedup) merge(entry *model.Entry) bool {
+	first, exists := d.first[entry.ID]
+	if exists {
+		first.MergeChans(entry)
+	}
+	return exists
+}
+
+// maxLen returns the length of the longest collection.
+func maxLen(collections []model.Collection) int {
+	maxSize := 0
+	for _, col := range collections {
+		if len(col) > maxSize {
+			maxSize = len(col)
+		}
+	}
+	return maxSize
+}
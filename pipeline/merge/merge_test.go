@@ -0,0 +1,121 @@
+package merge
+
+import (
+	"testing"
+
+	_ "github.com/uopensail/recgo-engine/internal/testlog"
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/ulib/sample"
+)
+
+// newCollection builds a collection of bare entries with the given IDs and scores.
+func newCollection(ids []int, scores []float32) model.Collection {
+	col := make(model.Collection, 0, len(ids))
+	for i, id := range ids {
+		entry := &model.Entry{
+			ID:       id,
+			KeyScore: model.KeyScore{Key: string(rune('a' + id)), Score: scores[i]},
+			Runtime:  *model.NewRuntime(sample.NewImmutableFeatures(sample.NewArena())),
+		}
+		entry.Set(model.ChannelsKey, &sample.Strings{Value: []string{}})
+		entry.Set(model.ReasonsKey, &sample.Strings{Value: []string{}})
+		col = append(col, entry)
+	}
+	return col
+}
+
+func ids(col model.Collection) []int {
+	ret := make([]int, 0, len(col))
+	for _, entry := range col {
+		ret = append(ret, entry.ID)
+	}
+	return ret
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func Test_RoundRobin(t *testing.T) {
+	conf := &model.MergeConfigure{Limit: 4}
+	names := []string{"r1", "r2"}
+	cols := []model.Collection{
+		newCollection([]int{1, 2, 3}, []float32{3, 2, 1}),
+		newCollection([]int{2, 4, 5}, []float32{3, 2, 1}),
+	}
+	got := ids(NewMerge(conf).Do(nil, names, cols))
+	if want := []int{1, 2, 4, 3}; !equal(got, want) {
+		t.Fatalf("round robin: want %v, got %v", want, got)
+	}
+}
+
+func Test_Quota(t *testing.T) {
+	conf := &model.MergeConfigure{Type: model.MergeTypeQuota, Limit: 4, Quotas: map[string]int{"r1": 1}}
+	names := []string{"r1", "r2"}
+	cols := []model.Collection{
+		newCollection([]int{1, 2, 3}, []float32{3, 2, 1}),
+		newCollection([]int{4, 5}, []float32{3, 2}),
+	}
+	got := ids(NewMerge(conf).Do(nil, names, cols))
+	if want := []int{1, 4, 5, 2}; !equal(got, want) {
+		t.Fatalf("quota: want %v, got %v", want, got)
+	}
+}
+
+func Test_QuotaMergesPastLimit(t *testing.T) {
+	conf := &model.MergeConfigure{Type: model.MergeTypeQuota, Limit: 2}
+	names := []string{"r1", "r2"}
+	cols := []model.Collection{
+		newCollection([]int{1, 2, 3}, []float32{3, 2, 1}),
+		newCollection([]int{4, 5, 1}, []float32{3, 2, 1}),
+	}
+	for c, col := range cols {
+		for _, entry := range col {
+			entry.AddChan(names[c], "test")
+		}
+	}
+	merged := NewMerge(conf).Do(nil, names, cols)
+	if want := []int{1, 4}; !equal(ids(merged), want) {
+		t.Fatalf("quota: want %v, got %v", want, ids(merged))
+	}
+	// r2 recalls item 1 after the limit is reached, its channel is still merged
+	chans, _ := merged[0].Get(model.ChannelsKey)
+	if got, _ := chans.GetStrings(); len(got) != 2 || got[1] != "r2" {
+		t.Errorf("channels of item 1: want [r1 r2], got %v", got)
+	}
+}
+
+func Test_RRF(t *testing.T) {
+	conf := &model.MergeConfigure{Type: model.MergeTypeRRF, Limit: 10}
+	names := []string{"r1", "r2"}
+	cols := []model.Collection{
+		newCollection([]int{1, 2, 3}, []float32{3, 2, 1}),
+		newCollection([]int{3, 2, 4}, []float32{3, 2, 1}),
+	}
+	got := ids(NewMerge(conf).Do(nil, names, cols))
+	// 2 and 3 are recalled twice, 2 ranks 2nd in both, 3 ranks 1st and 3rd
+	if want := []int{3, 2, 1, 4}; !equal(got, want) {
+		t.Fatalf("rrf: want %v, got %v", want, got)
+	}
+}
+
+func Test_Weighted(t *testing.T) {
+	conf := &model.MergeConfigure{Type: model.MergeTypeWeighted, Limit: 10, Weights: map[string]float32{"r2": 2}}
+	names := []string{"r1", "r2"}
+	cols := []model.Collection{
+		newCollection([]int{1, 2}, []float32{10, 0}),
+		newCollection([]int{3, 4}, []float32{0.5, 0.1}),
+	}
+	got := ids(NewMerge(conf).Do(nil, names, cols))
+	if want := []int{3, 1, 2, 4}; !equal(got, want) {
+		t.Fatalf("weighted: want %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,92 @@
+package merge
+
+import (
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// Quota merges recall outputs with layered round-robin while capping how many
+// new entries each recall may contribute. Quotas take precedence over Ratios;
+// recalls without either are uncapped. Unused quota is backfilled round-robin
+// from the remaining candidates, so the merged size still reaches conf.Limit when possible.
+type Quota struct {
+	conf *model.MergeConfigure // merge configuration
+}
+
+// NewQuota creates a new Quota merger.
+func NewQuota(conf *model.MergeConfigure) *Quota {
+	pStat := prome.NewStat("Merge.NewQuota")
+	defer pStat.End()
+	return &Quota{
+		conf: conf,
+	}
+}
+
+// quota returns the maximum number of new entries the named recall may contribute, or -1 if uncapped.
+func (q *Quota) quota(name string) int {
+	if n, ok := q.conf.Quotas[name]; ok {
+		return n
+	}
+	if ratio, ok := q.conf.Ratios[name]; ok {
+		return int(ratio * float32(q.conf.Limit))
+	}
+	return -1
+}
+
+// Do runs two round-robin passes: the first honours per-recall quotas,
+// the second backfills from entries skipped by the first.
+// Entries whose ID is already merged only contribute their channels, whatever the quotas
+// and the limit, so channel attribution is complete.
+func (q *Quota) Do(uCtx *userctx.UserContext, names []string, collections []model.Collection) model.Collection {
+	pStat := prome.NewStat("Merge.Quota.Do")
+	defer pStat.End()
+
+	maxSize := maxLen(collections)
+	d := newDedup(minInt(q.conf.Limit, maxSize*len(collections)))
+
+	quotas := make([]int, len(names))
+	for i, name := range names {
+		quotas[i] = q.quota(name)
+	}
+
+	// First pass: respect quotas
+	skipped := make([]model.Collection, len(collections))
+	for i := 0; i < maxSize; i++ {
+		for c, col := range collections {
+			if len(col) <= i || d.merge(col[i]) {
+				continue
+			}
+			if quotas[c] == 0 || len(d.ret) >= q.conf.Limit {
+				skipped[c] = append(skipped[c], col[i])
+				continue
+			}
+			d.add(col[i])
+			if quotas[c] > 0 {
+				quotas[c]--
+			}
+		}
+	}
+
+	// Second pass: backfill unused quota
+	backfilled := 0
+	maxSize = maxLen(skipped)
+	for i := 0; i < maxSize; i++ {
+		for _, col := range skipped {
+			if len(col) <= i || d.merge(col[i]) || len(d.ret) >= q.conf.Limit {
+				continue
+			}
+			d.add(col[i])
+			backfilled++
+		}
+	}
+
+	zlog.LOG.Debug("Merge.Quota.Do.Completed",
+		zap.Int("merged_count", len(d.ret)),
+		zap.Int("backfilled_count", backfilled),
+		zap.Int("limit", q.conf.Limit))
+	pStat.SetCounter(len(d.ret))
+	return d.ret
+}
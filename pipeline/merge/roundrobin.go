@@ -0,0 +1,62 @@
+package merge
+
+import (
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// RoundRobin merges recall outputs with layered round-robin in configured recall order:
+// the first entry of every recall, then the second of every recall, and so on.
+type RoundRobin struct {
+	conf *model.MergeConfigure // merge configuration
+}
+
+// NewRoundRobin creates a new RoundRobin merger.
+func NewRoundRobin(conf *model.MergeConfigure) *RoundRobin {
+	pStat := prome.NewStat("Merge.NewRoundRobin")
+	defer pStat.End()
+	return &RoundRobin{
+		conf: conf,
+	}
+}
+
+// Do interleaves the collections layer by layer, deduplicating by item ID,
+// and keeps the first conf.Limit entries. Entries past the limit still merge their
+// channels into the kept entries with the same ID.
+func (rr *RoundRobin) Do(uCtx *userctx.UserContext, names []string, collections []model.Collection) model.Collection {
+	pStat := prome.NewStat("Merge.RoundRobin.Do")
+	defer pStat.End()
+
+	maxSize := maxLen(collections)
+	d := newDedup(minInt(rr.conf.Limit, maxSize*len(collections)))
+
+	for i := 0; i < maxSize; i++ {
+		for _, col := range collections {
+			if len(col) <= i {
+				continue
+			}
+			if len(d.ret) < rr.conf.Limit {
+				d.add(col[i])
+			} else {
+				d.merge(col[i])
+			}
+		}
+	}
+
+	zlog.LOG.Debug("Merge.RoundRobin.Do.Completed",
+		zap.Int("merged_count", len(d.ret)),
+		zap.Int("limit", rr.conf.Limit))
+	pStat.SetCounter(len(d.ret))
+	return d.ret
+}
+
+// minInt returns the smaller of a and b.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -2,11 +2,11 @@ package pipeline
 
 import (
 	"fmt"
-	"sync"
 
 	"github.com/uopensail/recgo-engine/model"
 	"github.com/uopensail/recgo-engine/pipeline/constrains"
 	"github.com/uopensail/recgo-engine/pipeline/freqs"
+	"github.com/uopensail/recgo-engine/pipeline/merge"
 	"github.com/uopensail/recgo-engine/pipeline/rank"
 	"github.com/uopensail/recgo-engine/pipeline/recalls"
 	"github.com/uopensail/recgo-engine/userctx"
@@ -16,7 +16,8 @@ import (
 )
 
 const (
-	// MaxRecallLimit defines the maximum number of items that can pass from recall to ranking stage.
+	// MaxRecallLimit defines the default maximum number of items that can pass from recall to ranking stage.
+	// It applies when the pipeline's merge section does not configure a limit.
 	MaxRecallLimit = 512
 )
 
@@ -24,20 +25,25 @@ const (
 // A pipeline orchestrates filter, recall, rank, and constraints to produce final recommendations.
 type IPipeline interface {
 	GetName() string
+	GetTimeouts() model.TimeoutConfigure
 	Do(uCtx *userctx.UserContext) model.Collection
 }
 
 // Pipeline implements the IPipeline interface, holding the configured stages:
 // - Frequency filter
 // - Multiple recall strategies (parallel)
-// - Ranking strategy
+// - Merge strategy
+// - Ranking chain
 // - Constraints processor
 type Pipeline struct {
-	name       string
-	filter     freqs.IFilter
-	recalls    []recalls.IRecall
-	ranker     rank.IRank
-	constrains constrains.IConstrains
+	name        string
+	timeouts    model.TimeoutConfigure
+	filter      freqs.IFilter
+	recalls     []recalls.IRecall
+	recallNames []string // recall names aligned with recalls
+	merger      merge.IMerge
+	ranker      rank.IRank
+	constrains  constrains.IConstrains
 }
 
 // NewPipeline creates a new Pipeline from configuration.
@@ -49,30 +55,46 @@ func NewPipeline(conf *model.PipelineConfigure) *Pipeline {
 	filter := freqs.NewFreqController(conf.Freqs)
 
 	recallers := make([]recalls.IRecall, 0, len(conf.Recalls))
+	recallNames := make([]string, 0, len(conf.Recalls))
 	for _, recallConf := range conf.Recalls {
 		r := recalls.NewRecall(recallConf)
 		if r != nil {
 			recallers = append(recallers, r)
+			recallNames = append(recallNames, recallConf.GetName())
 		}
 	}
 
-	ranker := rank.NewRank(conf.Rank)
+	if conf.Merge.Limit <= 0 {
+		conf.Merge.Limit = MaxRecallLimit
+	}
+	merger := merge.NewMerge(&conf.Merge)
+
+	var ranker rank.IRank
+	if len(conf.Rank) > 0 {
+		ranker = rank.NewChain(conf.Rank)
+	}
 	constrains := constrains.NewConstains(conf.Constrains)
 
-	if filter == nil || len(recallers) == 0 || ranker == nil || constrains == nil {
+	if filter == nil || len(recallers) == 0 || merger == nil || ranker == nil || constrains == nil {
 		panic(fmt.Errorf("build pipeline fail: missing stage"))
 	}
 
 	zlog.LOG.Info("Pipeline.Created",
 		zap.String("name", conf.Name),
-		zap.Int("recallers_count", len(recallers)))
+		zap.Int("recallers_count", len(recallers)),
+		zap.String("merge_type", conf.Merge.Type),
+		zap.Int("rank_stages", len(conf.Rank)),
+		zap.Int("recall_limit", conf.Merge.Limit))
 
 	return &Pipeline{
-		name:       conf.Name,
-		filter:     filter,
-		recalls:    recallers,
-		ranker:     ranker,
-		constrains: constrains,
+		name:        conf.Name,
+		timeouts:    conf.Timeouts,
+		filter:      filter,
+		recalls:     recallers,
+		recallNames: recallNames,
+		merger:      merger,
+		ranker:      ranker,
+		constrains:  constrains,
 	}
 }
 
@@ -81,12 +103,18 @@ func (p *Pipeline) GetName() string {
 	return p.name
 }
 
+// GetTimeouts returns the per-stage budgets of the pipeline.
+func (p *Pipeline) GetTimeouts() model.TimeoutConfigure {
+	return p.timeouts
+}
+
 // Do executes the pipeline stages sequentially:
 // 1. Filter stage
-// 2. Parallel recall stage
-// 3. Merge and deduplicate recalled items
-// 4. Ranking stage
-// 5. Constraints stage
+// 2. Parallel recall stage, recalls missing the recall budget are dropped
+// 3. Merge and deduplicate recalled items with the configured merge strategy
+// 4. Ranking chain, keeping the output of the last completed stage when the rank budget is exhausted;
+// frequency penalties are attached before ranking
+// 5. Constraints stage, skipping remaining constraints when the budget is exhausted
 func (p *Pipeline) Do(uCtx *userctx.UserContext) model.Collection {
 	pStat := prome.NewStat("Pipeline.Do")
 	defer pStat.End()
@@ -95,69 +123,95 @@ func (p *Pipeline) Do(uCtx *userctx.UserContext) model.Collection {
 	uCtx.Filter = p.filter.Do(uCtx)
 
 	// Step 2: Parallel recall
-	var wg sync.WaitGroup
-	ch := make(chan model.Collection, len(p.recalls))
-	for i := range p.recalls {
-		wg.Add(1)
-		go func(idx int) {
-			defer wg.Done()
-			collection := p.recalls[idx].Do(uCtx)
-			if collection != nil {
-				ch <- collection
-				zlog.LOG.Debug("Pipeline.RecallCompleted",
-					zap.Int("recall_index", idx),
-					zap.Int("items_count", len(collection)))
-			}
-		}(i)
-	}
-	wg.Wait()
-	close(ch)
+	collections := p.recall(uCtx)
 
 	// Step 3: Merge recalled collections with deduplication
-	maxSize := 0
-	collections := make([]model.Collection, 0, len(p.recalls))
-	for col := range ch {
-		collections = append(collections, col)
-		if len(col) > maxSize {
-			maxSize = len(col)
-		}
-	}
-
-	filter := make(map[int]*model.Entry)
-	recall := make([]*model.Entry, 0, maxSize)
-
-	for i := 0; i < maxSize; i++ {
-		for _, col := range collections {
-			if len(col) <= i {
-				continue
-			}
-			entry := col[i]
-			if first, exists := filter[entry.ID]; exists {
-				first.MergeChans(entry)
-			} else {
-				filter[entry.ID] = entry
-				recall = append(recall, entry)
-			}
-		}
-	}
-
-	// Limit recall size to constant value
-	if len(recall) > MaxRecallLimit {
-		recall = recall[:MaxRecallLimit]
-	}
+	recall := p.merger.Do(uCtx, p.recallNames, collections)
 
 	zlog.LOG.Debug("Pipeline.MergedRecall",
 		zap.Int("merged_count", len(recall)),
 		zap.Int("original_collections", len(collections)))
 
-	// Step 4: Rank
-	ranked := p.ranker.Do(uCtx, recall)
+	// Step 4: Rank, demoted entries carry their penalties as runtime features
+	uCtx.Filter.Demote(recall)
+	ranked := p.rank(uCtx, recall)
 	zlog.LOG.Debug("Pipeline.Ranked", zap.Int("ranked_count", len(ranked)))
 
 	// Step 5: Constraints
-	final := p.constrains.Do(uCtx, ranked)
+	cCtx, cancel := uCtx.WithBudget(p.timeouts.ConstrainsTimeout())
+	final := p.constrains.Do(cCtx, ranked)
+	cancel()
 	zlog.LOG.Debug("Pipeline.Completed", zap.Int("final_count", len(final)))
 
 	pStat.SetCounter(len(final))
 	return final
 }
+
+// recallResult carries the output of a single recall back to the pipeline.
+type recallResult struct {
+	idx        int
+	collection model.Collection
+}
+
+// recall runs all recalls in parallel within the recall budget.
+// Results are kept in configured recall order; recalls that miss the deadline
+// are dropped and counted, and their late results are discarded.
+func (p *Pipeline) recall(uCtx *userctx.UserContext) []model.Collection {
+	rCtx, cancel := uCtx.WithBudget(p.timeouts.RecallTimeout())
+	defer cancel()
+
+	// Buffered so that late recalls never block after the pipeline moved on
+	ch := make(chan recallResult, len(p.recalls))
+	for i := range p.recalls {
+		go func(idx int) {
+			ch <- recallResult{idx: idx, collection: p.recalls[idx].Do(rCtx)}
+		}(i)
+	}
+
+	collections := make([]model.Collection, len(p.recalls))
+	done := make([]bool, len(p.recalls))
+	for pending := len(p.recalls); pending > 0; pending-- {
+		select {
+		case r := <-ch:
+			collections[r.idx] = r.collection
+			done[r.idx] = true
+			zlog.LOG.Debug("Pipeline.RecallCompleted",
+				zap.String("recall", p.recallNames[r.idx]),
+				zap.Int("items_count", len(r.collection)))
+		case <-rCtx.Done():
+			for idx := range p.recalls {
+				if done[idx] {
+					continue
+				}
+				tStat := prome.NewStat(fmt.Sprintf("Pipeline.RecallTimeout.%s", p.recallNames[idx]))
+				tStat.MarkErr()
+				tStat.End()
+				zlog.LOG.Warn("Pipeline.RecallTimeout",
+					zap.String("pipeline", p.name),
+					zap.String("recall", p.recallNames[idx]),
+					zap.Error(rCtx.Err()))
+			}
+			return collections
+		}
+	}
+	return collections
+}
+
+// rank runs the ranking chain within the rank budget.
+// Every stage works on cloned entries, so when the budget is exhausted the chain returns the
+// output of the last completed stage, scores included (the merged order if none completed).
+func (p *Pipeline) rank(uCtx *userctx.UserContext, recall model.Collection) model.Collection {
+	rCtx, cancel := uCtx.WithBudget(p.timeouts.RankTimeout())
+	defer cancel()
+
+	ranked := p.ranker.Do(rCtx, recall)
+	if err := rCtx.Err(); err != nil {
+		tStat := prome.NewStat("Pipeline.RankTimeout")
+		tStat.MarkErr()
+		tStat.End()
+		zlog.LOG.Warn("Pipeline.RankTimeout",
+			zap.String("pipeline", p.name),
+			zap.Error(err))
+	}
+	return ranked
+}
@@ -0,0 +1,145 @@
+package rank
+
+import (
+	"fmt"
+
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/pipeline/freqs"
+	"github.com/uopensail/recgo-engine/program"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// stage is a ranker of the chain with its truncation and condition.
+type stage struct {
+	name      string
+	ranker    IRank
+	truncate  int
+	condition *program.Program // nil when the stage always runs
+	penalize  bool             // apply frequency penalties before truncation
+}
+
+// Chain runs rank stages in sequence, e.g. a cheap pre-ranker trimming candidates
+// before an expensive model ranker, followed by a re-ranker.
+// Each stage reports its own metrics as "Rank.Stage.<name>".
+type Chain struct {
+	stages []*stage
+}
+
+// NewChain creates a rank chain from the stage configurations.
+// Panics if a stage ranker cannot be built, a condition cannot be parsed
+// or several stages apply the frequency penalties.
+func NewChain(confs []*model.RankStageConfigure) *Chain {
+	pStat := prome.NewStat("Rank.NewChain")
+	defer pStat.End()
+
+	stages := make([]*stage, 0, len(confs))
+	penalized := ""
+	for i, conf := range confs {
+		if conf == nil || conf.Rank == nil {
+			panic(fmt.Errorf("rank stage %d: missing rank configuration", i))
+		}
+		ranker := NewRank(conf.Rank)
+		if ranker == nil {
+			panic(fmt.Errorf("rank stage %s: unsupported type %s", conf.Rank.GetName(), conf.Rank.GetType()))
+		}
+
+		s := &stage{
+			name:     conf.Rank.GetName(),
+			ranker:   ranker,
+			truncate: conf.Truncate,
+			penalize: conf.ApplyPenalties,
+		}
+		if s.penalize {
+			if penalized != "" {
+				panic(fmt.Errorf("rank stages %s and %s both apply penalties", penalized, s.name))
+			}
+			penalized = s.name
+		}
+		if conf.Condition != "" {
+			condition, err := program.NewProgram(conf.Condition)
+			if err != nil {
+				zlog.LOG.Error("Rank.NewChain program create error",
+					zap.String("stage", s.name),
+					zap.Error(err))
+				panic(err)
+			}
+			s.condition = condition
+		}
+		stages = append(stages, s)
+	}
+
+	zlog.LOG.Info("Rank.Chain.Created", zap.Int("stages", len(stages)))
+	return &Chain{
+		stages: stages,
+	}
+}
+
+// Do runs the stages in order:
+// 1. Skip the stage if its condition on the user features does not hold.
+// 2. Rank a clone of the current collection, apply the frequency penalties if the stage is
+// configured to, then truncate it to the stage's size.
+// 3. Stop once the context is done; the output of the last completed stage is returned.
+// Rankers write scores into the entries, so a stage interrupted by the budget only touches
+// its clones and never leaves partially updated scores or a partially ranked order behind.
+func (c *Chain) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
+	pStat := prome.NewStat("Rank.Chain.Do")
+	defer pStat.End()
+
+	for _, s := range c.stages {
+		if err := uCtx.Err(); err != nil {
+			pStat.MarkErr()
+			zlog.LOG.Warn("Rank.Chain.Do.Timeout", zap.String("stage", s.name), zap.Error(err))
+			break
+		}
+		if !c.hit(uCtx, s) {
+			zlog.LOG.Debug("Rank.Chain.Do.StageSkipped", zap.String("stage", s.name))
+			continue
+		}
+
+		sStat := prome.NewStat(fmt.Sprintf("Rank.Stage.%s", s.name))
+		ranked := s.ranker.Do(uCtx, collection.Clone())
+		if err := uCtx.Err(); err != nil {
+			sStat.MarkErr()
+			sStat.End()
+			pStat.MarkErr()
+			zlog.LOG.Warn("Rank.Chain.Do.StageTimeout", zap.String("stage", s.name), zap.Error(err))
+			break
+		}
+		if s.penalize {
+			ranked = freqs.ApplyPenalties(ranked)
+		}
+		if s.truncate > 0 && len(ranked) > s.truncate {
+			ranked = ranked[:s.truncate]
+		}
+		sStat.SetCounter(len(ranked))
+		sStat.End()
+
+		zlog.LOG.Debug("Rank.Chain.Do.StageCompleted",
+			zap.String("stage", s.name),
+			zap.Int("input_count", len(collection)),
+			zap.Int("output_count", len(ranked)))
+		collection = ranked
+	}
+
+	pStat.SetCounter(len(collection))
+	return collection
+}
+
+// hit evaluates the stage condition against the user features.
+// Stages without condition always run; evaluation errors skip the stage.
+func (c *Chain) hit(uCtx *userctx.UserContext, s *stage) bool {
+	if s.condition == nil {
+		return true
+	}
+	value, err := s.condition.Eval(uCtx.Features)
+	if err != nil {
+		zlog.LOG.Error("Rank.Chain program eval error",
+			zap.String("stage", s.name),
+			zap.Error(err))
+		return false
+	}
+	return program.ToBool(value)
+}
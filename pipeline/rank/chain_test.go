@@ -0,0 +1,83 @@
+package rank
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/sample"
+)
+
+// interrupted scores entries like Rule until the budget runs out after the first entry.
+type interrupted struct {
+	cancel context.CancelFunc
+}
+
+func (r *interrupted) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
+	for i, entry := range collection {
+		if i == 1 {
+			r.cancel()
+		}
+		if uCtx.Err() != nil {
+			return collection
+		}
+		entry.KeyScore.Score = -1
+	}
+	return collection
+}
+
+func TestChainTimeout(t *testing.T) {
+	collection := make(model.Collection, 0, 3)
+	for i, key := range []string{"a", "b", "c"} {
+		collection = append(collection, &model.Entry{
+			ID:       i,
+			KeyScore: model.KeyScore{Key: key, Score: float32(3 - i)},
+			Runtime:  *model.NewRuntime(sample.NewImmutableFeatures(sample.NewArena())),
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	uCtx := &userctx.UserContext{Context: ctx, Features: sample.NewMutableFeatures()}
+	chain := &Chain{stages: []*stage{{name: "interrupted", ranker: &interrupted{cancel: cancel}}}}
+	ranked := chain.Do(uCtx, collection)
+
+	// The interrupted stage is discarded, scores and order stay as they came in
+	for i, entry := range ranked {
+		if entry.Key != collection[i].Key || entry.Score != float32(3-i) {
+			t.Errorf("position %d: got %s with score %f, want %s with score %d",
+				i, entry.Key, entry.Score, collection[i].Key, 3-i)
+		}
+	}
+}
+
+// keep ranks nothing, leaving the incoming order and scores.
+type keep struct{}
+
+func (keep) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
+	return collection
+}
+
+func TestChainApplyPenalties(t *testing.T) {
+	collection := make(model.Collection, 0, 2)
+	factors := []float32{0.5, 1}
+	for i, score := range []float32{0.9, 0.8} {
+		entry := &model.Entry{
+			ID:       i,
+			KeyScore: model.KeyScore{Key: string(rune('a' + i)), Score: score},
+			Runtime:  *model.NewRuntime(sample.NewImmutableFeatures(sample.NewArena())),
+		}
+		entry.Set(model.FreqFactorKey, &sample.Float32{Value: factors[i]})
+		collection = append(collection, entry)
+	}
+
+	uCtx := &userctx.UserContext{Context: context.Background(), Features: sample.NewMutableFeatures()}
+	chain := &Chain{stages: []*stage{{name: "pre", ranker: keep{}, truncate: 1, penalize: true}}}
+	ranked := chain.Do(uCtx, collection)
+
+	// The demoted entry loses its slot to the truncation
+	if len(ranked) != 1 || ranked[0].Key != "b" {
+		t.Fatalf("got %d entries starting with %s, want [b]", len(ranked), ranked[0].Key)
+	}
+}
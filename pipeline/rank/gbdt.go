@@ -0,0 +1,171 @@
+package rank
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/resources"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/sample"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// positionalFeature matches the positional feature names XGBoost ("f3") and LightGBM ("Column_3")
+// give to models trained without feature names.
+var positionalFeature = regexp.MustCompile(`^(?:f|Column_)(\d+)$`)
+
+// GBDT ranks items in-process with a gradient boosted tree model.
+// The model is resolved by name on every request, so a reloaded model file takes effect
+// without downtime. Each feature is read from the item features (Runtime.Basic) first,
+// then from the user features, then from the entry's runtime features; missing or
+// non-scalar features are treated as missing values.
+type GBDT struct {
+	conf *model.GBDTRankConfigure // rank configuration
+}
+
+// NewGBDT creates a new GBDT ranker.
+// Panics if conf.Model is not declared in AppConfig.Models or conf.Transform is unknown.
+func NewGBDT(conf *model.GBDTRankConfigure) *GBDT {
+	pStat := prome.NewStat("Rank.NewGBDT")
+	defer pStat.End()
+
+	if !config.AppConfigInstance.HasModel(conf.Model) {
+		zlog.LOG.Error("Rank.NewGBDT.ModelNotDeclared",
+			zap.String("name", conf.Name),
+			zap.String("model", conf.Model))
+		panic(fmt.Errorf("rank %s: model %s is not declared", conf.Name, conf.Model))
+	}
+
+	switch conf.Transform {
+	case "", model.TreeTransformRaw, model.TreeTransformSigmoid:
+	default:
+		zlog.LOG.Error("Rank.NewGBDT.UnknownTransform",
+			zap.String("name", conf.Name),
+			zap.String("transform", conf.Transform))
+		panic(fmt.Errorf("rank %s: unknown transform %s", conf.Name, conf.Transform))
+	}
+
+	return &GBDT{
+		conf: conf,
+	}
+}
+
+// Do scores every entry with the current tree model and sorts the collection:
+// 1. Resolve the model and its feature names.
+// 2. Read user features once, then build each entry's feature vector and score it.
+// 3. Sort the collection by score in descending order, preserving relative order of equal scores.
+// If the model is missing or the context is done before all entries are scored,
+// the collection is returned unsorted.
+func (g *GBDT) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
+	pStat := prome.NewStat("Rank.GBDT.Do")
+	defer pStat.End()
+
+	treeModel := resources.ResourceManagerInstance.GetTreeModel(g.conf.Model)
+	if treeModel == nil {
+		pStat.MarkErr()
+		zlog.LOG.Error("Rank.GBDT.Do.NoModel",
+			zap.String("rank", g.conf.Name),
+			zap.String("model", g.conf.Model))
+		return collection
+	}
+	uCtx.Versions.Set(g.conf.Model, treeModel.GetURL())
+
+	names := g.featureNames(treeModel)
+	transform := g.conf.Transform
+	if transform == "" {
+		transform = treeModel.GetTransform()
+	}
+
+	// User features are shared by all entries
+	userValues := make([]float32, len(names))
+	for slot, name := range names {
+		userValues[slot] = featureValue(uCtx.Features.Get(name))
+	}
+
+	x := make([]float32, len(names))
+	for _, entry := range collection {
+		// Stop scoring once the rank budget is exhausted, the order is still untouched
+		if err := uCtx.Err(); err != nil {
+			pStat.MarkErr()
+			zlog.LOG.Warn("GBDT.Do.Timeout", zap.Error(err))
+			return collection
+		}
+
+		for slot, name := range names {
+			if fea := entry.Runtime.Basic.Get(name); fea != nil {
+				x[slot] = featureValue(fea)
+			} else if !isMissing(userValues[slot]) {
+				x[slot] = userValues[slot]
+			} else {
+				x[slot] = featureValue(entry.Runtime.RunTime.Get(name))
+			}
+		}
+
+		score := treeModel.Score(x) + g.conf.BaseScore
+		if transform == model.TreeTransformSigmoid {
+			score = float32(1 / (1 + math.Exp(-float64(score))))
+		}
+		entry.KeyScore.Score = score
+
+		zlog.LOG.Debug("GBDT.Do.EntryScore",
+			zap.String("key", entry.KeyScore.Key),
+			zap.Float32("score", entry.KeyScore.Score))
+	}
+
+	sort.Stable(collection)
+
+	zlog.LOG.Debug("GBDT.Do.Completed",
+		zap.Int("total", len(collection)),
+		zap.String("model_version", treeModel.GetURL()))
+	pStat.SetCounter(len(collection))
+	return collection
+}
+
+// featureNames returns the feature names of the model's slots,
+// renaming positional names with conf.Features when configured.
+func (g *GBDT) featureNames(treeModel *model.TreeModel) []string {
+	features := treeModel.GetFeatures()
+	if len(g.conf.Features) == 0 {
+		return features
+	}
+
+	names := make([]string, len(features))
+	for slot, name := range features {
+		names[slot] = name
+		if m := positionalFeature.FindStringSubmatch(name); m != nil {
+			if idx, err := strconv.Atoi(m[1]); err == nil && idx < len(g.conf.Features) {
+				names[slot] = g.conf.Features[idx]
+			}
+		}
+	}
+	return names
+}
+
+// featureValue converts a scalar numeric feature into a model input, NaN if missing.
+func featureValue(fea sample.Feature) float32 {
+	if fea == nil {
+		return float32(math.NaN())
+	}
+	switch fea.Type() {
+	case sample.Int64Type:
+		v, _ := fea.GetInt64()
+		return float32(v)
+	case sample.Float32Type:
+		v, _ := fea.GetFloat32()
+		return v
+	default:
+		return float32(math.NaN())
+	}
+}
+
+// isMissing reports whether a model input is a missing value.
+func isMissing(v float32) bool {
+	return math.IsNaN(float64(v))
+}
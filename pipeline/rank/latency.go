@@ -0,0 +1,61 @@
+package rank
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyWindow is the number of recent latencies kept for percentile estimation.
+	latencyWindow = 512
+	// latencyMinSamples is the number of latencies needed before percentiles are trusted.
+	latencyMinSamples = 32
+)
+
+// latencies keeps a sliding window of recent request latencies.
+type latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+// newLatencies creates an empty latency window.
+func newLatencies() *latencies {
+	return &latencies{
+		samples: make([]time.Duration, 0, latencyWindow),
+	}
+}
+
+// Observe records a latency, evicting the oldest one when the window is full.
+func (l *latencies) Observe(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.samples) < latencyWindow {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencyWindow
+}
+
+// Percentile returns the p-th percentile (0-100) of the window.
+// Returns false until latencyMinSamples latencies were observed.
+func (l *latencies) Percentile(p float32) (time.Duration, bool) {
+	l.mu.Lock()
+	if len(l.samples) < latencyMinSamples {
+		l.mu.Unlock()
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), l.samples...)
+	l.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(float32(len(sorted)-1) * p / 100)
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx], true
+}
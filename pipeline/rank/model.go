@@ -2,13 +2,18 @@ package rank
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/outbound"
+	"github.com/uopensail/recgo-engine/program"
 	"github.com/uopensail/recgo-engine/userctx"
 	"github.com/uopensail/ulib/prome"
 	"github.com/uopensail/ulib/sample"
@@ -16,70 +21,316 @@ import (
 	"go.uber.org/zap"
 )
 
+// ScoresColumn is the request column carrying the pre-rank score of each item.
+const ScoresColumn = "i_ctx_score"
+
 // Request represents the payload sent to the model-based ranking service.
+// Columns holds the projected item features, one column per field aligned with Collection;
+// missing values are null.
 type Request struct {
-	Features   sample.Features `json:"features"`
-	Collection []string        `json:"collection"`
+	Features   sample.Features  `json:"features"`
+	Collection []string         `json:"collection"`
+	Columns    map[string][]any `json:"columns,omitempty"`
+}
+
+// ScoredItem is the score of an item returned by the model service.
+// Scores carries the named objective scores of multi-objective models.
+type ScoredItem struct {
+	Key    string             `json:"key"`
+	Score  float32            `json:"score"`
+	Scores map[string]float32 `json:"scores,omitempty"`
 }
 
 // Response represents the ranking results returned by the model service.
 type Response struct {
-	Code int              `json:"code"`
-	Msg  string           `json:"msg"`
-	Data []model.KeyScore `json:"data"`
+	Code int           `json:"code"`
+	Msg  string        `json:"msg"`
+	Data []*ScoredItem `json:"data"`
 }
 
 // Modeler ranks items using scores from an external model service.
 type Modeler struct {
-	conf   *model.ModelBasedRankConfigure // rank configuration
-	client *http.Client                   // HTTP client
+	conf      *model.ModelBasedRankConfigure // rank configuration
+	client    *outbound.Client               // shared outbound client
+	fusion    *program.Program               // compiled fusion expression, nil without fusion
+	latencies *latencies                     // recent request latencies driving the hedge delay
+	fallback  IRank                          // ranker used when the model degrades, nil without fallback
 }
 
-// NewModeler creates a new Modeler with configured HTTP client settings.
+// NewModeler creates a new Modeler using the outbound client named by conf.Client.
+// Panics if the client is unknown, the fusion expression cannot be parsed
+// or the fallback ranker cannot be built.
 func NewModeler(conf *model.ModelBasedRankConfigure) *Modeler {
 	pStat := prome.NewStat("Rank.NewModeler")
 	defer pStat.End()
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-		},
+
+	var fusion *program.Program
+	if conf.Fusion != "" {
+		var err error
+		fusion, err = program.NewProgram(conf.Fusion)
+		if err != nil {
+			zlog.LOG.Error("Rank.NewModeler program create error",
+				zap.String("name", conf.Name),
+				zap.Error(err))
+			panic(err)
+		}
+	}
+
+	var fallback IRank
+	if conf.Fallback != nil {
+		fallback = NewRank(conf.Fallback)
+		if fallback == nil {
+			panic(fmt.Errorf("rank %s: unsupported fallback type %s", conf.Name, conf.Fallback.GetType()))
+		}
+	}
+
+	client := outbound.ClientManagerInstance.Get(conf.Client)
+	if client == nil {
+		zlog.LOG.Error("Rank.NewModeler.UnknownClient",
+			zap.String("name", conf.Name),
+			zap.String("client", conf.Client))
+		panic(fmt.Errorf("rank %s: unknown client %s", conf.Name, conf.Client))
 	}
+
 	return &Modeler{
-		conf:   conf,
-		client: client,
+		conf:      conf,
+		client:    client,
+		fusion:    fusion,
+		latencies: newLatencies(),
+		fallback:  fallback,
 	}
 }
 
 // Do sends the current collection to the model service for scoring,
-// updates each entry's Score, and returns the sorted collection.
+// stores the objective scores as runtime features, updates each entry's Score
+// (fused when a fusion expression is configured), and returns the sorted collection.
+// With a batch size the collection is scored by parallel chunk requests; a failed chunk
+// only zeroes its own items. If every chunk fails, or the share of scored items is below
+// conf.MinCoverage, the degradation is recorded and the fallback ranker takes over;
+// without fallback the collection is returned unchanged when every chunk fails.
 func (m *Modeler) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
 	pStat := prome.NewStat("Rank.Modeler.Do")
 	defer pStat.End()
-	// Prepare list of keys
-	keys := make([]string, 0, len(collection))
+
+	chunks := m.split(collection)
+	features := m.userFeatures(uCtx)
+
+	results := make([]map[string]*ScoredItem, len(chunks))
+	var waitGroup sync.WaitGroup
+	for i := range chunks {
+		waitGroup.Add(1)
+		go func(idx int) {
+			defer waitGroup.Done()
+			scores, err := m.hedgedCall(uCtx, features, chunks[idx])
+			if err != nil {
+				cStat := prome.NewStat("Rank.Modeler.ChunkError")
+				cStat.MarkErr()
+				cStat.End()
+				zlog.LOG.Error("Rank.Modeler.Do.ChunkError",
+					zap.Int("chunk", idx),
+					zap.Int("chunk_size", len(chunks[idx])),
+					zap.Error(err))
+				return
+			}
+			results[idx] = scores
+		}(i)
+	}
+	waitGroup.Wait()
+
+	// Map scores
+	failed := 0
+	scoreMap := make(map[string]*ScoredItem, len(collection))
+	for _, scores := range results {
+		if scores == nil {
+			failed++
+			continue
+		}
+		for key, item := range scores {
+			scoreMap[key] = item
+		}
+	}
+	if failed == len(chunks) {
+		pStat.MarkErr()
+		return m.degrade(uCtx, collection, "error", "all model requests failed")
+	}
+	if coverage := m.coverage(collection, scoreMap); coverage < m.conf.MinCoverage {
+		pStat.MarkErr()
+		reason := fmt.Sprintf("coverage %.2f below %.2f", coverage, m.conf.MinCoverage)
+		if m.fallback != nil {
+			return m.degrade(uCtx, collection, "coverage", reason)
+		}
+		m.degrade(uCtx, collection, "coverage", reason)
+	}
+
+	// Assign scores to collection
+	weights := m.weights(uCtx)
+	for _, entry := range collection {
+		item, ok := scoreMap[entry.Key]
+		if !ok {
+			item = &ScoredItem{Key: entry.Key}
+		}
+		for _, objective := range m.conf.Objectives {
+			entry.Set(objective, &sample.Float32{Value: item.Scores[objective]})
+		}
+
+		entry.KeyScore.Score = item.Score
+		if m.fusion != nil {
+			entry.KeyScore.Score = m.fuse(uCtx, entry, weights)
+		}
+	}
+
+	// Sort using the built-in sort.Interface of model.Collection
+	sort.Stable(collection)
+
+	zlog.LOG.Debug("Rank.Modeler.Do.Completed",
+		zap.Int("total", len(collection)),
+		zap.Int("chunks", len(chunks)),
+		zap.Int("failed_chunks", failed),
+		zap.String("url", m.conf.URL))
+	return collection
+}
+
+// coverage returns the share of the collection scored by the model.
+func (m *Modeler) coverage(collection model.Collection, scoreMap map[string]*ScoredItem) float32 {
+	if len(collection) == 0 {
+		return 1
+	}
+	scored := 0
 	for _, entry := range collection {
+		if _, ok := scoreMap[entry.Key]; ok {
+			scored++
+		}
+	}
+	return float32(scored) / float32(len(collection))
+}
+
+// degrade records the degradation in the user context and metrics ("Rank.Modeler.Degrade.<kind>"),
+// then ranks the collection with the fallback ranker. Without fallback the collection is
+// returned unchanged.
+func (m *Modeler) degrade(uCtx *userctx.UserContext, collection model.Collection, kind string, reason string) model.Collection {
+	dStat := prome.NewStat(fmt.Sprintf("Rank.Modeler.Degrade.%s", kind))
+	dStat.MarkErr()
+	dStat.End()
+
+	if m.fallback != nil {
+		reason = fmt.Sprintf("%s, fallback to %s", reason, m.conf.Fallback.GetName())
+	}
+	uCtx.Degradations.Set(m.conf.Name, reason)
+	zlog.LOG.Warn("Rank.Modeler.Degrade",
+		zap.String("rank", m.conf.Name),
+		zap.String("reason", reason))
+
+	if m.fallback == nil {
+		return collection
+	}
+	return m.fallback.Do(uCtx, collection)
+}
+
+// split cuts the collection into chunks of at most conf.BatchSize entries.
+func (m *Modeler) split(collection model.Collection) []model.Collection {
+	size := m.conf.BatchSize
+	if size <= 0 || size >= len(collection) {
+		return []model.Collection{collection}
+	}
+	chunks := make([]model.Collection, 0, (len(collection)+size-1)/size)
+	for start := 0; start < len(collection); start += size {
+		chunks = append(chunks, collection[start:min(start+size, len(collection))])
+	}
+	return chunks
+}
+
+// hedgedCall scores a chunk, sending one duplicate request if the first one is still pending
+// after the hedge delay. The first successful response wins and the other request is cancelled.
+func (m *Modeler) hedgedCall(uCtx *userctx.UserContext, features sample.Features, chunk model.Collection) (map[string]*ScoredItem, error) {
+	delay, ok := m.hedgeDelay()
+	if !ok {
+		return m.call(uCtx, features, chunk)
+	}
+
+	ctx, cancel := context.WithCancel(uCtx)
+	defer cancel()
+
+	type result struct {
+		scores map[string]*ScoredItem
+		err    error
+	}
+	// Buffered so that the losing request never blocks
+	ch := make(chan result, 2)
+	launch := func() {
+		go func() {
+			scores, err := m.call(ctx, features, chunk)
+			ch <- result{scores: scores, err: err}
+		}()
+	}
+
+	launch()
+	pending, hedged := 1, false
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case r := <-ch:
+			pending--
+			if r.err == nil {
+				return r.scores, nil
+			}
+			lastErr = r.err
+		case <-timer.C:
+			if !hedged {
+				hedged = true
+				pending++
+				hStat := prome.NewStat("Rank.Modeler.Hedge")
+				hStat.End()
+				launch()
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+// hedgeDelay returns the delay after which a duplicate request is sent.
+// Returns false if hedging is disabled or no delay is known yet.
+func (m *Modeler) hedgeDelay() (time.Duration, bool) {
+	if m.conf.Hedge == nil {
+		return 0, false
+	}
+	if delay, ok := m.latencies.Percentile(m.conf.Hedge.Percentile); ok {
+		return delay, true
+	}
+	if m.conf.Hedge.Delay > 0 {
+		return time.Duration(m.conf.Hedge.Delay) * time.Millisecond, true
+	}
+	return 0, false
+}
+
+// call sends one scoring request for a chunk and returns its scores by item key.
+// Latencies of successful calls feed the hedge delay.
+func (m *Modeler) call(ctx context.Context, features sample.Features, chunk model.Collection) (map[string]*ScoredItem, error) {
+	start := time.Now()
+
+	// Prepare list of keys
+	keys := make([]string, 0, len(chunk))
+	for _, entry := range chunk {
 		keys = append(keys, entry.Key)
 	}
 
 	// Construct request payload
 	request := Request{
-		Features:   uCtx.Features,
+		Features:   features,
 		Collection: keys,
+		Columns:    m.columns(chunk),
 	}
 	data, err := json.Marshal(request)
 	if err != nil {
-		zlog.LOG.Error("Rank.Modeler.Do.MarshalError", zap.Error(err))
-		return collection
+		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", m.conf.URL, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", m.conf.URL, bytes.NewBuffer(data))
 	if err != nil {
-		zlog.LOG.Error("Rank.Modeler.Do.NewRequestError", zap.Error(err))
-		return collection
+		return nil, fmt.Errorf("new request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
@@ -87,59 +338,130 @@ func (m *Modeler) Do(uCtx *userctx.UserContext, collection model.Collection) mod
 	// Send HTTP request
 	resp, err := m.client.Do(req)
 	if err != nil {
-		zlog.LOG.Error("Rank.Modeler.Do.HTTPError", zap.Error(err))
-		return collection
+		return nil, fmt.Errorf("http: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check HTTP status
 	if resp.StatusCode != http.StatusOK {
-		zlog.LOG.Warn("Rank.Modeler.Do.NonOKStatus",
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("status", resp.Status))
-		return collection
+		return nil, fmt.Errorf("non-OK status: %s", resp.Status)
 	}
 
 	// Read and parse response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		zlog.LOG.Error("Rank.Modeler.Do.ReadBodyError", zap.Error(err))
-		return collection
+		return nil, fmt.Errorf("read body: %w", err)
 	}
 	var responseData Response
 	if err := json.Unmarshal(body, &responseData); err != nil {
-		zlog.LOG.Error("Rank.Modeler.Do.UnmarshalError", zap.Error(err))
-		return collection
+		return nil, fmt.Errorf("unmarshal response: %w", err)
 	}
 
 	// Business-level error check
 	if responseData.Code != 0 {
-		zlog.LOG.Warn("Rank.Modeler.Do.AppError",
-			zap.Int("code", responseData.Code),
-			zap.String("msg", responseData.Msg))
-		return collection
+		return nil, fmt.Errorf("app error %d: %s", responseData.Code, responseData.Msg)
 	}
+	m.latencies.Observe(time.Since(start))
 
-	// Map scores
-	scoreMap := make(map[string]float32, len(responseData.Data))
-	for _, kv := range responseData.Data {
-		scoreMap[kv.Key] = kv.Score
+	scores := make(map[string]*ScoredItem, len(responseData.Data))
+	for _, item := range responseData.Data {
+		if item != nil {
+			scores[item.Key] = item
+		}
 	}
+	return scores, nil
+}
 
-	// Assign scores to collection
-	for _, entry := range collection {
-		if score, ok := scoreMap[entry.Key]; ok {
-			entry.KeyScore.Score = score
-		} else {
-			entry.KeyScore.Score = 0
+// weights returns the fusion weights: the configured defaults, each overridden by the
+// user feature of the same name when present.
+func (m *Modeler) weights(uCtx *userctx.UserContext) *sample.MutableFeatures {
+	weights := sample.NewMutableFeatures()
+	for name, weight := range m.conf.Weights {
+		if fea := uCtx.Features.Get(name); fea != nil {
+			switch fea.Type() {
+			case sample.Float32Type, sample.Int64Type:
+				weights.Set(name, fea)
+				continue
+			}
 		}
+		weights.Set(name, &sample.Float32{Value: weight})
 	}
+	return weights
+}
 
-	// Sort using the built-in sort.Interface of model.Collection
-	sort.Stable(collection)
+// fuse evaluates the fusion expression for an entry, returning 0 on error.
+func (m *Modeler) fuse(uCtx *userctx.UserContext, entry *model.Entry, weights *sample.MutableFeatures) float32 {
+	// Make sure the parameter order matches other modules: basic, user features, runtime; weights last
+	value, err := m.fusion.Eval(entry.Runtime.Basic, uCtx.Features, entry.Runtime.RunTime, weights)
+	if err != nil {
+		zlog.LOG.Error("Rank.Modeler.Fuse program eval error",
+			zap.String("key", entry.KeyScore.Key),
+			zap.Error(err))
+		return 0
+	}
+	score, ok := program.ToFloat32(value)
+	if !ok {
+		zlog.LOG.Error("Rank.Modeler.Fuse.ScoreExtractError", zap.String("key", entry.KeyScore.Key))
+		return 0
+	}
+	return score
+}
 
-	zlog.LOG.Debug("Rank.Modeler.Do.Completed",
-		zap.Int("total", len(collection)),
-		zap.String("url", m.conf.URL))
-	return collection
+// userFeatures returns the user features sent to the model service,
+// restricted to the projected user fields when configured.
+func (m *Modeler) userFeatures(uCtx *userctx.UserContext) sample.Features {
+	if m.conf.Projection == nil || len(m.conf.Projection.UserFields) == 0 {
+		return uCtx.Features
+	}
+	features := sample.NewMutableFeatures()
+	for _, field := range m.conf.Projection.UserFields {
+		if fea := uCtx.Features.Get(field); fea != nil {
+			features.Set(field, fea)
+		}
+	}
+	return features
+}
+
+// columns builds the projected item feature columns aligned with the collection.
+// Returns nil without projection.
+func (m *Modeler) columns(collection model.Collection) map[string][]any {
+	projection := m.conf.Projection
+	if projection == nil {
+		return nil
+	}
+
+	columns := make(map[string][]any, len(projection.ItemFields)+2)
+	column := func(name string, value func(entry *model.Entry) any) {
+		values := make([]any, len(collection))
+		for i, entry := range collection {
+			values[i] = value(entry)
+		}
+		columns[name] = values
+	}
+
+	for _, field := range projection.ItemFields {
+		column(field, func(entry *model.Entry) any {
+			if fea := entry.Runtime.Basic.Get(field); fea != nil {
+				return fea.Get()
+			}
+			if fea := entry.Runtime.RunTime.Get(field); fea != nil {
+				return fea.Get()
+			}
+			return nil
+		})
+	}
+	if projection.Channels {
+		column(model.ChannelsKey, func(entry *model.Entry) any {
+			if fea := entry.Runtime.RunTime.Get(model.ChannelsKey); fea != nil {
+				return fea.Get()
+			}
+			return nil
+		})
+	}
+	if projection.Scores {
+		column(ScoresColumn, func(entry *model.Entry) any {
+			return entry.KeyScore.Score
+		})
+	}
+	return columns
 }
@@ -0,0 +1,116 @@
+package rank
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	_ "github.com/uopensail/recgo-engine/internal/testlog"
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/outbound"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/sample"
+)
+
+func TestMain(m *testing.M) {
+	outbound.ClientManagerInstance = outbound.NewClientManager(nil)
+	os.Exit(m.Run())
+}
+
+func TestModelerChunks(t *testing.T) {
+	// Scores every item by its position in the key list, fails any chunk containing "bad"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Collection []string `json:"collection"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		resp := Response{}
+		for _, key := range req.Collection {
+			if key == "bad" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			resp.Data = append(resp.Data, &ScoredItem{Key: key, Score: float32(key[0] - 'a' + 1)})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	collection := make(model.Collection, 0, 5)
+	for _, key := range []string{"a", "b", "bad", "d", "e"} {
+		collection = append(collection, &model.Entry{
+			KeyScore: model.KeyScore{Key: key, Score: 100},
+			Runtime:  *model.NewRuntime(sample.NewImmutableFeatures(sample.NewArena())),
+		})
+	}
+
+	uCtx := &userctx.UserContext{Context: context.Background(), Features: sample.NewMutableFeatures()}
+	modeler := NewModeler(&model.ModelBasedRankConfigure{Name: "m", Type: model.RankTypeModel, URL: server.URL, BatchSize: 2})
+	ranked := modeler.Do(uCtx, collection)
+
+	// Chunk {bad, d} fails and is zeroed, the others keep their model scores
+	want := map[string]float32{"a": 1, "b": 2, "bad": 0, "d": 0, "e": 5}
+	for _, entry := range ranked {
+		if entry.Score != want[entry.Key] {
+			t.Errorf("%s: got score %f, want %f", entry.Key, entry.Score, want[entry.Key])
+		}
+	}
+	if ranked[0].Key != "e" || ranked[1].Key != "b" {
+		t.Errorf("unexpected order: %s %s", ranked[0].Key, ranked[1].Key)
+	}
+}
+
+func TestModelerFallback(t *testing.T) {
+	// Only scores item "a"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Response{Data: []*ScoredItem{{Key: "a", Score: 1}}})
+	}))
+	defer server.Close()
+
+	collection := make(model.Collection, 0, 3)
+	for i, key := range []string{"a", "b", "c"} {
+		entry := &model.Entry{
+			ID:       i,
+			KeyScore: model.KeyScore{Key: key},
+			Runtime:  *model.NewRuntime(sample.NewImmutableFeatures(sample.NewArena())),
+		}
+		entry.Set("popularity", &sample.Float32{Value: []float32{1, 3, 2}[i]})
+		collection = append(collection, entry)
+	}
+
+	uCtx := &userctx.UserContext{
+		Context:      context.Background(),
+		Features:     sample.NewMutableFeatures(),
+		Degradations: userctx.NewDegradations(),
+	}
+	modeler := NewModeler(&model.ModelBasedRankConfigure{
+		Name:        "m",
+		Type:        model.RankTypeModel,
+		URL:         server.URL,
+		MinCoverage: 0.5,
+		Fallback: &model.RuleBasedRankConfigure{
+			Name: "popular",
+			Type: model.RankTypeRule,
+			Rule: "popularity",
+		},
+	})
+	ranked := modeler.Do(uCtx, collection)
+
+	// Coverage 1/3 is too low, the rule fallback orders by popularity
+	var keys string
+	for _, entry := range ranked {
+		keys += entry.Key
+	}
+	if keys != "bca" {
+		t.Fatalf("fallback ranker not applied: got order %s, want bca", keys)
+	}
+	if ranked[0].Score != 3 {
+		t.Errorf("fallback score: got %f, want 3", ranked[0].Score)
+	}
+	if _, ok := uCtx.Degradations.Map()["m"]; !ok {
+		t.Errorf("degradation not recorded: %v", uCtx.Degradations.Map())
+	}
+}
@@ -18,6 +18,7 @@ type IRank interface {
 // - model.RankTypeChannelPriority -> ChannelPriority ranker
 // - model.RankTypeRule            -> Rule-based ranker
 // - model.RankTypeModel           -> Model-based ranker
+// - model.RankTypeGBDT            -> In-process tree model ranker
 func NewRank(conf model.IRank) IRank {
 	switch conf.GetType() {
 	case model.RankTypeChannelPriority:
@@ -35,6 +36,11 @@ func NewRank(conf model.IRank) IRank {
 			return NewModeler(c)
 		}
 		zlog.LOG.Error("NewRank.TypeAssertError", zap.String("expected", "ModelBasedRankConfigure"))
+	case model.RankTypeGBDT:
+		if c, ok := conf.(*model.GBDTRankConfigure); ok {
+			return NewGBDT(c)
+		}
+		zlog.LOG.Error("NewRank.TypeAssertError", zap.String("expected", "GBDTRankConfigure"))
 	default:
 		zlog.LOG.Warn("NewRank.UnknownType", zap.String("type", conf.GetType()))
 	}
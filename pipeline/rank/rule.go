@@ -47,11 +47,19 @@ func NewRule(conf *model.RuleBasedRankConfigure) *Rule {
 // 1. Evaluate the configured rule for each entry using its runtime features and user context.
 // 2. Set the entry's KeyScore.Score.
 // 3. Sort the collection by score in descending order, preserving relative order of equal scores.
+// If the context is done before all entries are scored, the collection is returned unsorted.
 func (rule *Rule) Do(uCtx *userctx.UserContext, collection model.Collection) model.Collection {
 	pStat := prome.NewStat("Rank.Rule.Do")
 	defer pStat.End()
 
 	for _, entry := range collection {
+		// Stop scoring once the rank budget is exhausted, the order is still untouched
+		if err := uCtx.Err(); err != nil {
+			pStat.MarkErr()
+			zlog.LOG.Warn("Rule.Do.Timeout", zap.Error(err))
+			return collection
+		}
+
 		// Evaluate rule
 		value, err := rule.program.Eval(entry.Runtime.Basic, uCtx.Features, entry.Runtime.RunTime)
 		if err != nil {
@@ -61,7 +69,7 @@ func (rule *Rule) Do(uCtx *userctx.UserContext, collection model.Collection) mod
 
 		// Default score
 		entry.KeyScore.Score = 0.0
-		score, ok := value.(float32)
+		score, ok := program.ToFloat32(value)
 		if ok {
 			entry.KeyScore.Score = score
 		} else {
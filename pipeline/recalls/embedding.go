@@ -0,0 +1,163 @@
+package recalls
+
+import (
+	"fmt"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/resources"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/sample"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// embeddingSearchRounds caps the searches of an embedding recall, each doubling k
+// while too few neighbours pass the frequency filter.
+const embeddingSearchRounds = 4
+
+// Embedder implements embedding-based recall over an in-process VectorIndex.
+// The query vector is read from the user features or the related item features,
+// and the index is resolved by name on every request to pick up reloads.
+type Embedder struct {
+	conf *model.EmbeddingRecallConfigure // Recall configuration
+}
+
+// NewEmbedder creates a new Embedder instance.
+// Panics if conf.Index is not declared in AppConfig.Vectors or conf.Source is unknown.
+func NewEmbedder(conf *model.EmbeddingRecallConfigure) *Embedder {
+	pStat := prome.NewStat("Recall.NewEmbedder")
+	defer pStat.End()
+
+	if !config.AppConfigInstance.HasVector(conf.Index) {
+		zlog.LOG.Error("Recall.NewEmbedder.IndexNotDeclared",
+			zap.String("name", conf.Name),
+			zap.String("index", conf.Index))
+		panic(fmt.Errorf("recall %s: vector index %s is not declared", conf.Name, conf.Index))
+	}
+
+	if conf.Source == "" {
+		conf.Source = model.EmbeddingSourceUser
+	}
+	if conf.Source != model.EmbeddingSourceUser && conf.Source != model.EmbeddingSourceRelated {
+		zlog.LOG.Error("Recall.NewEmbedder.UnknownSource",
+			zap.String("name", conf.Name),
+			zap.String("source", conf.Source))
+		panic(fmt.Errorf("recall %s: unknown embedding source %s", conf.Name, conf.Source))
+	}
+
+	zlog.LOG.Info("Embedder.Created",
+		zap.String("name", conf.Name),
+		zap.String("index", conf.Index),
+		zap.String("source", conf.Source),
+		zap.String("field", conf.Field))
+	return &Embedder{
+		conf: conf,
+	}
+}
+
+// Do runs the recall process and returns candidate entries.
+// 1. Resolve the current version of the configured vector index.
+// 2. Read the query vector from the configured source.
+// 3. Retrieve the top conf.Count nearest items passing the frequency filter,
+// doubling the number of searched neighbours until enough pass.
+func (e *Embedder) Do(uCtx *userctx.UserContext) model.Collection {
+	pStat := prome.NewStat("Recall.Embedder.Do")
+	defer pStat.End()
+
+	index := resources.ResourceManagerInstance.GetVectorIndex(e.conf.Index)
+	if index == nil {
+		pStat.MarkErr()
+		zlog.LOG.Error("Recall.Embedder.Do.NoIndex",
+			zap.String("recall", e.conf.Name),
+			zap.String("index", e.conf.Index))
+		return nil
+	}
+	uCtx.Versions.Set(e.conf.Index, index.GetURL())
+
+	var fea sample.Feature
+	switch e.conf.Source {
+	case model.EmbeddingSourceRelated:
+		if uCtx.Related != nil {
+			fea = uCtx.Related.Get(e.conf.Field)
+		}
+	default:
+		fea = uCtx.Features.Get(e.conf.Field)
+	}
+	if fea == nil {
+		zlog.LOG.Info("Recall.Embedder.Do.NoVector",
+			zap.String("recall", e.conf.Name),
+			zap.String("field", e.conf.Field))
+		return nil
+	}
+
+	vector, err := fea.GetFloat32s()
+	if err != nil {
+		pStat.MarkErr()
+		zlog.LOG.Error("Recall.Embedder.Do.VectorTypeError",
+			zap.String("recall", e.conf.Name),
+			zap.String("field", e.conf.Field),
+			zap.Error(err))
+		return nil
+	}
+
+	// Over-fetch by the number of excluded keys so filtered items don't eat quota,
+	// then widen the search while items capped by attribute rules leave fewer than Count
+	k := e.conf.Count
+	if uCtx.Filter != nil {
+		k += len(uCtx.Filter.Exclude())
+	}
+	var collection model.Collection
+	filteredCount := 0
+	for round := 0; ; round++ {
+		neighbours, err := index.Search(vector, k, e.conf.Ef)
+		if err != nil {
+			pStat.MarkErr()
+			zlog.LOG.Error("Recall.Embedder.Do.SearchError",
+				zap.String("recall", e.conf.Name),
+				zap.Error(err))
+			return nil
+		}
+
+		collection, filteredCount = e.collect(uCtx, neighbours)
+		// Stop once enough items survive, the index is exhausted or the budget is spent
+		if len(collection) >= e.conf.Count || len(neighbours) < k ||
+			round+1 >= embeddingSearchRounds || uCtx.Err() != nil {
+			break
+		}
+		k *= 2
+	}
+	reportFiltered(e.conf.Name, filteredCount)
+
+	zlog.LOG.Debug("Recall.Embedder.Do.Completed",
+		zap.Int("requested_count", e.conf.Count),
+		zap.Int("returned_count", len(collection)),
+		zap.String("index_version", index.GetURL()),
+	)
+	pStat.SetCounter(len(collection))
+	return collection
+}
+
+// collect turns neighbours into entries until Count entries pass the frequency filter.
+// Returns the entries and the number of filtered neighbours.
+func (e *Embedder) collect(uCtx *userctx.UserContext, neighbours []model.KeyScore) (model.Collection, int) {
+	collection := make(model.Collection, 0, minInt(e.conf.Count, len(neighbours)))
+	filteredCount := 0
+	for _, kv := range neighbours {
+		if len(collection) >= e.conf.Count {
+			break
+		}
+		entry, err := model.NewEntry(kv, uCtx.Items)
+		if err != nil {
+			continue
+		}
+		if filtered(uCtx, entry.ID) {
+			filteredCount++
+			continue
+		}
+		entry.AddChan(e.conf.Name, fmt.Sprintf("recall by embedding: %s", e.conf.Field))
+		collection = append(collection, entry)
+	}
+	return collection, filteredCount
+}
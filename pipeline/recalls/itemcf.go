@@ -0,0 +1,222 @@
+package recalls
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/resources"
+	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// seed is an item the user acted on, used as the starting point of item-to-item recall.
+type seed struct {
+	key       string  // item key
+	action    string  // action that produced the record
+	timestamp int64   // UNIX timestamp of the most recent record
+	weight    float32 // time-decayed weight
+}
+
+// neighbour accumulates the score of a recalled item across seeds.
+type neighbour struct {
+	key   string  // item key
+	score float32 // sum of seed weight * similarity
+	best  float32 // largest single seed contribution
+	seed  *seed   // seed with the largest contribution, reported as itemcf:<action>:<seed key>
+}
+
+// ItemCF implements item-to-item collaborative recall.
+// Seeds are the user's most recent items from the u_r_<action>_ids / u_r_<action>_ts features;
+// each seed is looked up in an item-to-item similarity index and neighbours are merged
+// with the seed's time-decayed weight.
+type ItemCF struct {
+	conf *model.ItemCFRecallConfigure // Recall configuration
+}
+
+// NewItemCF creates a new ItemCF instance.
+// Panics if conf.Index is not declared in AppConfig.Indexes or no action is configured.
+func NewItemCF(conf *model.ItemCFRecallConfigure) *ItemCF {
+	pStat := prome.NewStat("Recall.NewItemCF")
+	defer pStat.End()
+
+	if !config.AppConfigInstance.HasIndex(conf.Index) {
+		zlog.LOG.Error("Recall.NewItemCF.IndexNotDeclared",
+			zap.String("name", conf.Name),
+			zap.String("index", conf.Index))
+		panic(fmt.Errorf("recall %s: index %s is not declared", conf.Name, conf.Index))
+	}
+
+	if len(conf.Actions) == 0 {
+		zlog.LOG.Error("Recall.NewItemCF.NoActions", zap.String("name", conf.Name))
+		panic(fmt.Errorf("recall %s: no actions configured", conf.Name))
+	}
+
+	zlog.LOG.Info("ItemCF.Created",
+		zap.String("name", conf.Name),
+		zap.String("index", conf.Index),
+		zap.Strings("actions", conf.Actions),
+		zap.Int("seeds", conf.Seeds),
+		zap.Int("half_life", conf.HalfLife),
+		zap.Int("fan_out", conf.FanOut))
+	return &ItemCF{
+		conf: conf,
+	}
+}
+
+// Do runs the recall process and returns candidate entries.
+// 1. Collect the most recent distinct seeds across the configured actions.
+// 2. Look up each seed's neighbours and accumulate time-decayed scores.
+// 3. Return top N entries based on conf.Count, each explained by its strongest seed.
+func (icf *ItemCF) Do(uCtx *userctx.UserContext) model.Collection {
+	pStat := prome.NewStat("Recall.ItemCF.Do")
+	defer pStat.End()
+
+	index := resources.ResourceManagerInstance.GetIndex(icf.conf.Index)
+	if index == nil {
+		pStat.MarkErr()
+		zlog.LOG.Error("Recall.ItemCF.Do.NoIndex",
+			zap.String("recall", icf.conf.Name),
+			zap.String("index", icf.conf.Index))
+		return nil
+	}
+	uCtx.Versions.Set(icf.conf.Index, index.GetURL())
+
+	seeds := icf.collectSeeds(uCtx)
+	if len(seeds) == 0 {
+		zlog.LOG.Info("Recall.ItemCF.Do.NoSeeds", zap.String("recall", icf.conf.Name))
+		return nil
+	}
+
+	// Seeds are items the user already acted on, never recall them again
+	seedKeys := make(map[string]struct{}, len(seeds))
+	for _, s := range seeds {
+		seedKeys[s.key] = struct{}{}
+	}
+
+	neighbours := make(map[string]*neighbour, len(seeds)*icf.conf.FanOut)
+	for _, s := range seeds {
+		entry, err := index.Get(s.key)
+		if err != nil {
+			continue
+		}
+		values := entry.Values
+		if icf.conf.FanOut > 0 && len(values) > icf.conf.FanOut {
+			values = values[:icf.conf.FanOut]
+		}
+		for _, kv := range values {
+			if _, ok := seedKeys[kv.Key]; ok {
+				continue
+			}
+			contribution := s.weight * kv.Score
+			n, ok := neighbours[kv.Key]
+			if !ok {
+				n = &neighbour{key: kv.Key}
+				neighbours[kv.Key] = n
+			}
+			n.score += contribution
+			if n.seed == nil || contribution > n.best {
+				n.best = contribution
+				n.seed = s
+			}
+		}
+	}
+
+	sorted := make([]*neighbour, 0, len(neighbours))
+	for _, n := range neighbours {
+		sorted = append(sorted, n)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].score != sorted[j].score {
+			return sorted[i].score > sorted[j].score
+		}
+		return sorted[i].key < sorted[j].key
+	})
+
+	collection := make(model.Collection, 0, minInt(icf.conf.Count, len(sorted)))
+	filteredCount := 0
+	for _, n := range sorted {
+		if len(collection) >= icf.conf.Count {
+			break
+		}
+		entry, err := model.NewEntry(model.KeyScore{Key: n.key, Score: n.score}, uCtx.Items)
+		if err != nil {
+			continue
+		}
+		if filtered(uCtx, entry.ID) {
+			filteredCount++
+			continue
+		}
+		entry.AddChan(icf.conf.Name, fmt.Sprintf("itemcf:%s:%s", n.seed.action, n.seed.key))
+		collection = append(collection, entry)
+	}
+	reportFiltered(icf.conf.Name, filteredCount)
+
+	if len(collection) == 0 {
+		zlog.LOG.Info("Recall.ItemCF.Do.NoResult", zap.String("recall", icf.conf.Name))
+		return nil
+	}
+
+	zlog.LOG.Debug("Recall.ItemCF.Do.Completed",
+		zap.Int("seeds", len(seeds)),
+		zap.Int("requested_count", icf.conf.Count),
+		zap.Int("returned_count", len(collection)),
+		zap.String("index_version", index.GetURL()),
+	)
+	pStat.SetCounter(len(collection))
+	return collection
+}
+
+// collectSeeds gathers the user's records of all configured actions, keeps the most recent
+// record per item, and returns up to conf.Seeds seeds ordered from newest to oldest.
+func (icf *ItemCF) collectSeeds(uCtx *userctx.UserContext) []*seed {
+	latest := make(map[string]*seed, 64)
+	for _, action := range icf.conf.Actions {
+		itemKeys, timestamps, err := uCtx.GetActionRecords(action)
+		if err != nil {
+			if !errors.Is(err, userctx.ErrNoActionRecords) {
+				zlog.LOG.Error("Recall.ItemCF.ActionRecordsError",
+					zap.String("recall", icf.conf.Name),
+					zap.String("action", action),
+					zap.Error(err))
+			}
+			continue
+		}
+		for i, key := range itemKeys {
+			if s, ok := latest[key]; ok && s.timestamp >= timestamps[i] {
+				continue
+			}
+			latest[key] = &seed{key: key, action: action, timestamp: timestamps[i]}
+		}
+	}
+
+	seeds := make([]*seed, 0, len(latest))
+	for _, s := range latest {
+		seeds = append(seeds, s)
+	}
+	sort.Slice(seeds, func(i, j int) bool {
+		if seeds[i].timestamp != seeds[j].timestamp {
+			return seeds[i].timestamp > seeds[j].timestamp
+		}
+		return seeds[i].key < seeds[j].key
+	})
+	if icf.conf.Seeds > 0 && len(seeds) > icf.conf.Seeds {
+		seeds = seeds[:icf.conf.Seeds]
+	}
+
+	now := time.Now().Unix()
+	for _, s := range seeds {
+		s.weight = 1
+		if icf.conf.HalfLife > 0 {
+			age := float64(maxInt(int(now-s.timestamp), 0))
+			s.weight = float32(math.Exp2(-age / float64(icf.conf.HalfLife)))
+		}
+	}
+	return seeds
+}
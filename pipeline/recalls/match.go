@@ -3,8 +3,10 @@ package recalls
 import (
 	"fmt"
 
+	"github.com/uopensail/recgo-engine/config"
 	"github.com/uopensail/recgo-engine/model"
 	"github.com/uopensail/recgo-engine/program"
+	"github.com/uopensail/recgo-engine/resources"
 	"github.com/uopensail/recgo-engine/userctx"
 	"github.com/uopensail/ulib/prome"
 	"github.com/uopensail/ulib/zlog"
@@ -12,17 +14,26 @@ import (
 )
 
 // Matcher implements match-based recall using Minia expressions and an InvertedIndex.
+// The index is resolved by name on every request, so a reload by resources.Finder
+// is picked up without rebuilding the pipeline.
 type Matcher struct {
 	conf    *model.MatchRecallConfigure // Recall configuration
-	index   *model.InvertedIndex        // Candidate index
 	program *program.Program            // Compiled expression program
 }
 
 // NewMatcher creates a new Matcher instance with compiled Minia expression.
+// Panics if conf.Index is not declared in AppConfig.Indexes.
 func NewMatcher(conf *model.MatchRecallConfigure) *Matcher {
 	pStat := prome.NewStat("Recall.NewMatcher")
 	defer pStat.End()
 
+	if !config.AppConfigInstance.HasIndex(conf.Index) {
+		zlog.LOG.Error("Recall.NewMatcher.IndexNotDeclared",
+			zap.String("name", conf.Name),
+			zap.String("index", conf.Index))
+		panic(fmt.Errorf("recall %s: index %s is not declared", conf.Name, conf.Index))
+	}
+
 	program, err := program.NewProgram(conf.Expr)
 	if err != nil {
 		zlog.LOG.Error("Recall.NewMatcher program create error",
@@ -30,27 +41,34 @@ func NewMatcher(conf *model.MatchRecallConfigure) *Matcher {
 		panic(err)
 	}
 
-	zlog.LOG.Info("Matcher.Created", zap.String("name", conf.Name), zap.String("expr", conf.Expr))
+	zlog.LOG.Info("Matcher.Created",
+		zap.String("name", conf.Name),
+		zap.String("expr", conf.Expr),
+		zap.String("index", conf.Index))
 	return &Matcher{
 		conf:    conf,
-		index:   nil, // needs to be injected externally
 		program: program,
 	}
 }
 
 // Do runs the recall process and returns candidate entries.
-// 1. Evaluate expression to get matching keys.
-// 2. Merge candidates across keys using layered round-robin.
-// 3. Return top N entries based on conf.Count.
+// 1. Resolve the current version of the configured index.
+// 2. Evaluate expression to get matching keys.
+// 3. Merge candidates across keys using layered round-robin.
+// 4. Return top N entries based on conf.Count.
 func (m *Matcher) Do(uCtx *userctx.UserContext) model.Collection {
 	pStat := prome.NewStat("Recall.Matcher.Do")
 	defer pStat.End()
 
-	if m.index == nil {
+	index := resources.ResourceManagerInstance.GetIndex(m.conf.Index)
+	if index == nil {
 		pStat.MarkErr()
-		zlog.LOG.Error("Recall.Matcher.Do.NoIndex", zap.String("recall", m.conf.Name))
+		zlog.LOG.Error("Recall.Matcher.Do.NoIndex",
+			zap.String("recall", m.conf.Name),
+			zap.String("index", m.conf.Index))
 		return nil
 	}
+	uCtx.Versions.Set(m.conf.Index, index.GetURL())
 
 	var value any
 	var err error
@@ -75,7 +93,8 @@ func (m *Matcher) Do(uCtx *userctx.UserContext) model.Collection {
 
 	zlog.LOG.Debug("Matcher.Do.KeysExtracted", zap.Int("count", len(keys)))
 
-	ret := mergeCandidatesRoundRobin(keys, m.index, uCtx.Items, m.conf.Name)
+	ret, filteredCount := mergeCandidatesRoundRobin(uCtx, keys, index, m.conf.Name)
+	reportFiltered(m.conf.Name, filteredCount)
 
 	if len(ret) == 0 {
 		pStat.MarkErr()
@@ -87,20 +106,24 @@ func (m *Matcher) Do(uCtx *userctx.UserContext) model.Collection {
 	zlog.LOG.Debug("Matcher.Do.Completed",
 		zap.Int("requested_count", m.conf.Count),
 		zap.Int("returned_count", count),
+		zap.String("index_version", index.GetURL()),
 	)
 	pStat.SetCounter(count)
 	return ret[:count]
 }
 
 // mergeCandidatesRoundRobin merges multiple candidate lists using layered round-robin (Z-like interleaving).
+// Candidates excluded by the frequency filter are skipped; their number is returned alongside the entries.
 func mergeCandidatesRoundRobin(
+	uCtx *userctx.UserContext,
 	keys []string,
 	index *model.InvertedIndex,
-	items *model.Items,
 	recallName string,
-) []*model.Entry {
+) ([]*model.Entry, int) {
+	items := uCtx.Items
 	filter := make(map[int]struct{})
 	ret := make([]*model.Entry, 0)
+	filteredCount := 0
 
 	// 计算候选最大长度
 	maxSize := 0
@@ -121,6 +144,10 @@ func mergeCandidatesRoundRobin(
 							continue
 						}
 						filter[id] = struct{}{}
+						if filtered(uCtx, id) {
+							filteredCount++
+							continue
+						}
 						entry, err := model.NewEntry(k, items)
 						if err != nil {
 							zlog.LOG.Warn("mergeCandidatesRoundRobin.NewEntryError",
@@ -135,7 +162,7 @@ func mergeCandidatesRoundRobin(
 		}
 	}
 
-	return ret
+	return ret, filteredCount
 }
 
 // maxInt returns the larger of a and b.
@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"time"
 
 	"github.com/uopensail/recgo-engine/model"
+	"github.com/uopensail/recgo-engine/outbound"
 	"github.com/uopensail/recgo-engine/userctx"
 	"github.com/uopensail/ulib/prome"
 	"github.com/uopensail/ulib/sample"
@@ -39,21 +39,21 @@ type Response struct {
 // Modeler is an implementation of IRecall that fetches recall results from an external model service via HTTP.
 type Modeler struct {
 	conf   *model.ModelRecallConfigure // recall configuration, contains URL & other parameters
-	client *http.Client                // HTTP client
+	client *outbound.Client            // shared outbound client
 }
 
-// NewModeler creates a Modeler with customized HTTP client settings.
+// NewModeler creates a Modeler using the outbound client named by conf.Client.
+// Panics if the client is unknown.
 func NewModeler(conf *model.ModelRecallConfigure) *Modeler {
 	pStat := prome.NewStat("Recall.NewModeler")
 	defer pStat.End()
 
-	client := &http.Client{
-		Timeout: 30 * time.Second, // request timeout
-		Transport: &http.Transport{
-			MaxIdleConns:        100,              // max idle connections globally
-			MaxIdleConnsPerHost: 10,               // max idle connections per host
-			IdleConnTimeout:     90 * time.Second, // idle connection timeout
-		},
+	client := outbound.ClientManagerInstance.Get(conf.Client)
+	if client == nil {
+		zlog.LOG.Error("Recall.NewModeler.UnknownClient",
+			zap.String("name", conf.Name),
+			zap.String("client", conf.Client))
+		panic(fmt.Errorf("recall %s: unknown client %s", conf.Name, conf.Client))
 	}
 
 	zlog.LOG.Info("Modeler.Created",
@@ -91,7 +91,7 @@ func (m *Modeler) Do(uCtx *userctx.UserContext) model.Collection {
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", m.conf.URL, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(uCtx, "POST", m.conf.URL, bytes.NewBuffer(data))
 	if err != nil {
 		pStat.MarkErr()
 		zlog.LOG.Error("Recall.Modeler.Do.NewRequestError", zap.Error(err))
@@ -143,22 +143,27 @@ func (m *Modeler) Do(uCtx *userctx.UserContext) model.Collection {
 		return nil
 	}
 
-	// Build collection from response data
+	// Build collection from response data, dropping items the service failed to exclude
 	collection := make([]*model.Entry, 0, len(resp.Data))
+	filteredCount := 0
 	for _, kv := range resp.Data {
-		id, feas := uCtx.Items.GetByKey(kv.Key)
+		id, _ := uCtx.Items.GetByKey(kv.Key)
 		if id < 0 {
 			continue
 		}
+		if filtered(uCtx, id) {
+			filteredCount++
+			continue
+		}
 
-		entry := &model.Entry{
-			ID:       id,
-			KeyScore: kv,
-			Runtime:  *model.NewRuntime(feas),
+		entry, err := model.NewEntry(kv, uCtx.Items)
+		if err != nil {
+			continue
 		}
 		entry.AddChan(m.conf.Name, fmt.Sprintf("recall by model: %s", m.conf.URL))
 		collection = append(collection, entry)
 	}
+	reportFiltered(m.conf.Name, filteredCount)
 
 	count := minInt(m.conf.Count, len(collection))
 	zlog.LOG.Debug("Recall.Modeler.Do.Completed",
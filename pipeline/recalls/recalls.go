@@ -1,8 +1,11 @@
 package recalls
 
 import (
+	"fmt"
+
 	"github.com/uopensail/recgo-engine/model"
 	"github.com/uopensail/recgo-engine/userctx"
+	"github.com/uopensail/ulib/prome"
 	"github.com/uopensail/ulib/zlog"
 	"go.uber.org/zap"
 )
@@ -17,6 +20,8 @@ type IRecall interface {
 // Supported types:
 //   - model.RecallTypeMatch -> returns a Matcher-based recall
 //   - model.RecallTypeModel -> returns a Modeler-based recall
+//   - model.RecallTypeEmbedding -> returns an Embedder-based recall
+//   - model.RecallTypeItemCF -> returns an ItemCF-based recall
 //
 // Returns nil if the type is unknown or if type assertion fails.
 func NewRecall(conf model.IRecall) IRecall {
@@ -34,9 +39,33 @@ func NewRecall(conf model.IRecall) IRecall {
 			return NewModeler(cfg)
 		}
 		zlog.LOG.Error("NewRecall.TypeAssertionFailed", zap.String("expected", "ModelRecallConfigure"), zap.String("actual", recallType))
+	case model.RecallTypeEmbedding:
+		if cfg, ok := conf.(*model.EmbeddingRecallConfigure); ok {
+			return NewEmbedder(cfg)
+		}
+		zlog.LOG.Error("NewRecall.TypeAssertionFailed", zap.String("expected", "EmbeddingRecallConfigure"), zap.String("actual", recallType))
+	case model.RecallTypeItemCF:
+		if cfg, ok := conf.(*model.ItemCFRecallConfigure); ok {
+			return NewItemCF(cfg)
+		}
+		zlog.LOG.Error("NewRecall.TypeAssertionFailed", zap.String("expected", "ItemCFRecallConfigure"), zap.String("actual", recallType))
 	default:
 		zlog.LOG.Error("NewRecall.UnknownType", zap.String("type", recallType))
 	}
 
 	return nil
 }
+
+// filtered reports whether the item ID is excluded by the request's frequency filter.
+// Recalls check it before truncating to their count, so filtered items never consume quota.
+func filtered(uCtx *userctx.UserContext, id int) bool {
+	return uCtx.Filter != nil && uCtx.Filter.Exists(id)
+}
+
+// reportFiltered records how many candidates the named recall lost to frequency filtering.
+func reportFiltered(recall string, count int) {
+	pStat := prome.NewStat(fmt.Sprintf("Recall.Filtered.%s", recall))
+	defer pStat.End()
+	pStat.SetCounter(count)
+	zlog.LOG.Debug("Recall.Filtered", zap.String("recall", recall), zap.Int("filtered_count", count))
+}
@@ -0,0 +1,121 @@
+package program
+
+import "github.com/uopensail/ulib/sample"
+
+// ToBool interprets the result of a condition expression.
+// Comparisons yield bool; numeric results are true when non-zero, matching
+// the "returns 1" convention of existing conditions.
+func ToBool(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	default:
+		return false
+	}
+}
+
+// ToFloat32 interprets the numeric result of a score expression.
+// Float features are evaluated as float64 and integer features as int64.
+// Returns false if the value is not numeric.
+func ToFloat32(value any) (float32, bool) {
+	switch v := value.(type) {
+	case float64:
+		return float32(v), true
+	case float32:
+		return v, true
+	case int:
+		return float32(v), true
+	case int64:
+		return float32(v), true
+	default:
+		return 0, false
+	}
+}
+
+// ToFeature converts the result of an expression into a feature.
+// Integers become Int64, floats Float32, booleans Int64 (1 or 0), and slices their list types.
+// Returns false if the value has no feature representation.
+func ToFeature(value any) (sample.Feature, bool) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return &sample.Int64{Value: 1}, true
+		}
+		return &sample.Int64{Value: 0}, true
+	case int:
+		return &sample.Int64{Value: int64(v)}, true
+	case int64:
+		return &sample.Int64{Value: v}, true
+	case float64:
+		return &sample.Float32{Value: float32(v)}, true
+	case float32:
+		return &sample.Float32{Value: v}, true
+	case string:
+		return &sample.String{Value: v}, true
+	case []string:
+		return &sample.Strings{Value: v}, true
+	case []int64:
+		return &sample.Int64s{Value: v}, true
+	case []float64:
+		values := make([]float32, len(v))
+		for i, f := range v {
+			values[i] = float32(f)
+		}
+		return &sample.Float32s{Value: values}, true
+	case []any:
+		return sliceToFeature(v)
+	default:
+		return nil, false
+	}
+}
+
+// sliceToFeature converts a slice built by an expression (e.g. map or filter results)
+// whose elements all share one scalar type.
+func sliceToFeature(values []any) (sample.Feature, bool) {
+	if len(values) == 0 {
+		return &sample.Strings{Value: []string{}}, true
+	}
+	switch values[0].(type) {
+	case string:
+		ret := make([]string, len(values))
+		for i, value := range values {
+			s, ok := value.(string)
+			if !ok {
+				return nil, false
+			}
+			ret[i] = s
+		}
+		return &sample.Strings{Value: ret}, true
+	case int, int64:
+		ret := make([]int64, len(values))
+		for i, value := range values {
+			switch n := value.(type) {
+			case int:
+				ret[i] = int64(n)
+			case int64:
+				ret[i] = n
+			default:
+				return nil, false
+			}
+		}
+		return &sample.Int64s{Value: ret}, true
+	case float64:
+		ret := make([]float32, len(values))
+		for i, value := range values {
+			f, ok := value.(float64)
+			if !ok {
+				return nil, false
+			}
+			ret[i] = float32(f)
+		}
+		return &sample.Float32s{Value: ret}, true
+	default:
+		return nil, false
+	}
+}
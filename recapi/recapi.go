@@ -24,11 +24,26 @@ type ItemInfo struct {
 
 // Response represents the standard API response for recommendation results.
 type Response struct {
-	Code     int         `json:"code"`               // Business status code: 0 = success, non-zero = error
-	Message  string      `json:"message,omitempty"`  // Message for status or error description
-	TraceId  string      `json:"trace_id,omitempty"` // Request ID
-	UserId   string      `json:"user_id,omitempty"`  // User ID
-	Pipeline string      `json:"pipeline,omitempty"` // Pipeline name used
-	Items    []*ItemInfo `json:"items,omitempty"`    // Recommended items
-	Count    int         `json:"count,omitempty"`    // Number of items returned
+	Code         int               `json:"code"`                   // Business status code: 0 = success, non-zero = error
+	Message      string            `json:"message,omitempty"`      // Message for status or error description
+	TraceId      string            `json:"trace_id,omitempty"`     // Request ID
+	UserId       string            `json:"user_id,omitempty"`      // User ID
+	Pipeline     string            `json:"pipeline,omitempty"`     // Pipeline name used
+	Items        []*ItemInfo       `json:"items,omitempty"`        // Recommended items
+	Count        int               `json:"count,omitempty"`        // Number of items returned
+	Versions     map[string]string `json:"versions,omitempty"`     // Versions of the resources that served the request
+	Degradations map[string]string `json:"degradations,omitempty"` // Stages served in degraded mode and why
+}
+
+// Action represents a single user action on an item reported to the engine.
+type Action struct {
+	Action    string `json:"action"`              // Action name, e.g. "click"
+	Item      string `json:"item"`                // Item ID
+	Timestamp int64  `json:"timestamp,omitempty"` // UNIX timestamp in seconds, defaults to the receive time
+}
+
+// ActionRequest represents the action ingestion API request parameters.
+type ActionRequest struct {
+	UserId  string    `json:"user_id"` // User ID
+	Actions []*Action `json:"actions"` // Actions to record
 }
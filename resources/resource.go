@@ -8,10 +8,14 @@ import (
 	"go.uber.org/zap"
 )
 
-// ResourceManager manages Items and Index resources, periodically reloading them.
+// ResourceManager manages Items, Index, Vector, Model, Profile and List resources, periodically reloading them.
 type ResourceManager struct {
-	indexes map[string]*Finder
-	items   *Finder
+	indexes  map[string]*Finder
+	vectors  map[string]*Finder
+	models   map[string]*Finder
+	profiles map[string]*Finder
+	lists    map[string]*Finder
+	items    *Finder
 }
 
 // NewResourceManager creates and initializes all resources defined in the AppConfig.
@@ -36,6 +40,62 @@ func NewResourceManager(conf *config.AppConfig) *ResourceManager {
 		indexes[res.Name] = index
 	}
 
+	vectors := make(map[string]*Finder, len(conf.Vectors))
+
+	// Initialize each vector index finder
+	for _, res := range conf.Vectors {
+		vector, err := NewFinder(res.Dir, model.NewVectorIndex)
+		if err != nil {
+			zlog.LOG.Fatal("ResourceManager: failed to initialize vector index",
+				zap.String("name", res.Name),
+				zap.String("dir", res.Dir),
+				zap.Error(err))
+		}
+		vectors[res.Name] = vector
+	}
+
+	models := make(map[string]*Finder, len(conf.Models))
+
+	// Initialize each tree model finder
+	for _, res := range conf.Models {
+		treeModel, err := NewFinder(res.Dir, model.NewTreeModel)
+		if err != nil {
+			zlog.LOG.Fatal("ResourceManager: failed to initialize model",
+				zap.String("name", res.Name),
+				zap.String("dir", res.Dir),
+				zap.Error(err))
+		}
+		models[res.Name] = treeModel
+	}
+
+	profiles := make(map[string]*Finder, len(conf.Profiles))
+
+	// Initialize each user profile table finder
+	for _, res := range conf.Profiles {
+		table, err := NewFinder(res.Dir, model.NewProfiles)
+		if err != nil {
+			zlog.LOG.Fatal("ResourceManager: failed to initialize profiles",
+				zap.String("name", res.Name),
+				zap.String("dir", res.Dir),
+				zap.Error(err))
+		}
+		profiles[res.Name] = table
+	}
+
+	lists := make(map[string]*Finder, len(conf.Lists))
+
+	// Initialize each item list finder
+	for _, res := range conf.Lists {
+		list, err := NewFinder(res.Dir, model.NewItemList)
+		if err != nil {
+			zlog.LOG.Fatal("ResourceManager: failed to initialize list",
+				zap.String("name", res.Name),
+				zap.String("dir", res.Dir),
+				zap.Error(err))
+		}
+		lists[res.Name] = list
+	}
+
 	// Initialize items finder
 	items, err := NewFinder(conf.Items.Dir, model.NewItems)
 	if err != nil {
@@ -45,12 +105,20 @@ func NewResourceManager(conf *config.AppConfig) *ResourceManager {
 	}
 
 	rm := &ResourceManager{
-		indexes: indexes,
-		items:   items,
+		indexes:  indexes,
+		vectors:  vectors,
+		models:   models,
+		profiles: profiles,
+		lists:    lists,
+		items:    items,
 	}
 
 	zlog.LOG.Info("ResourceManager: initialized successfully",
 		zap.Int("indexes_count", len(indexes)),
+		zap.Int("vectors_count", len(vectors)),
+		zap.Int("models_count", len(models)),
+		zap.Int("profiles_count", len(profiles)),
+		zap.Int("lists_count", len(lists)),
 		zap.String("items_dir", conf.Items.Dir))
 	return rm
 }
@@ -71,5 +139,45 @@ func (m *ResourceManager) GetIndex(name string) *model.InvertedIndex {
 	return nil
 }
 
+// GetVectorIndex returns the current VectorIndex resource by name.
+// Returns nil if the vector index is not found.
+func (m *ResourceManager) GetVectorIndex(name string) *model.VectorIndex {
+	if vector, ok := m.vectors[name]; ok {
+		res := vector.Get()
+		return res.(*model.VectorIndex)
+	}
+	return nil
+}
+
+// GetTreeModel returns the current TreeModel resource by name.
+// Returns nil if the model is not found.
+func (m *ResourceManager) GetTreeModel(name string) *model.TreeModel {
+	if treeModel, ok := m.models[name]; ok {
+		res := treeModel.Get()
+		return res.(*model.TreeModel)
+	}
+	return nil
+}
+
+// GetProfiles returns the current Profiles resource by name.
+// Returns nil if the profile table is not found.
+func (m *ResourceManager) GetProfiles(name string) *model.Profiles {
+	if profiles, ok := m.profiles[name]; ok {
+		res := profiles.Get()
+		return res.(*model.Profiles)
+	}
+	return nil
+}
+
+// GetItemList returns the current ItemList resource by name.
+// Returns nil if the list is not found.
+func (m *ResourceManager) GetItemList(name string) *model.ItemList {
+	if list, ok := m.lists[name]; ok {
+		res := list.Get()
+		return res.(*model.ItemList)
+	}
+	return nil
+}
+
 // ResourceManagerInstance is the global singleton instance.
 var ResourceManagerInstance *ResourceManager
@@ -10,6 +10,7 @@ import (
 	"github.com/uopensail/recgo-engine/config"
 	"github.com/uopensail/recgo-engine/recapi"
 	"github.com/uopensail/recgo-engine/report"
+	"github.com/uopensail/recgo-engine/store"
 	"github.com/uopensail/recgo-engine/strategy"
 	"github.com/uopensail/recgo-engine/userctx"
 	"github.com/uopensail/ulib/prome"
@@ -35,6 +36,7 @@ func (srv *Services) RegisterGinRouter(ginEngine *gin.Engine) {
 	{
 		apiV1.POST("/feeds", srv.FeedsHandler)
 		apiV1.POST("/related", srv.RelatedHandler)
+		apiV1.POST("/actions", srv.ActionsHandler)
 	}
 }
 
@@ -55,7 +57,8 @@ func (srv *Services) FeedsHandler(gCtx *gin.Context) {
 		return
 	}
 
-	uCtx := userctx.NewUserContext(ctx, &req)
+	timeouts := strategy.StrategyInstance.FeedsTimeouts(req.Pipeline)
+	uCtx := userctx.NewUserContext(ctx, &req, timeouts.FeaturesTimeout())
 	resp := strategy.StrategyInstance.Feeds(uCtx)
 
 	if resp == nil {
@@ -87,7 +90,8 @@ func (srv *Services) RelatedHandler(gCtx *gin.Context) {
 		return
 	}
 
-	uCtx := userctx.NewUserContext(ctx, &req)
+	timeouts := strategy.StrategyInstance.RelatedTimeouts(req.Pipeline)
+	uCtx := userctx.NewUserContext(ctx, &req, timeouts.FeaturesTimeout())
 	resp := strategy.StrategyInstance.Related(uCtx)
 
 	if resp == nil {
@@ -102,6 +106,57 @@ func (srv *Services) RelatedHandler(gCtx *gin.Context) {
 	gCtx.JSON(http.StatusOK, resp)
 }
 
+// ActionsHandler ingests user actions into the action store for frequency control.
+func (srv *Services) ActionsHandler(gCtx *gin.Context) {
+	pStat := prome.NewStat("HTTP.ActionsHandler")
+	defer pStat.End()
+
+	if store.ActionStoreInstance == nil {
+		pStat.MarkErr()
+		gCtx.JSON(http.StatusServiceUnavailable, recapi.Response{
+			Code:    -1,
+			Message: "action store is disabled",
+		})
+		return
+	}
+
+	var req recapi.ActionRequest
+	if err := gCtx.ShouldBindJSON(&req); err != nil || req.UserId == "" {
+		pStat.MarkErr()
+		message := "user_id is empty"
+		if err != nil {
+			message = err.Error()
+		}
+		gCtx.JSON(http.StatusBadRequest, recapi.Response{
+			Code:    -1,
+			Message: message,
+		})
+		return
+	}
+
+	now := time.Now().Unix()
+	records := make([]store.Record, 0, len(req.Actions))
+	for _, action := range req.Actions {
+		if action == nil || action.Action == "" || action.Item == "" {
+			continue
+		}
+		timestamp := action.Timestamp
+		if timestamp <= 0 {
+			timestamp = now
+		}
+		records = append(records, store.Record{Action: action.Action, Key: action.Item, Timestamp: timestamp})
+	}
+	store.ActionStoreInstance.Add(req.UserId, records...)
+	pStat.SetCounter(len(records))
+
+	gCtx.JSON(http.StatusOK, recapi.Response{
+		Code:    0,
+		Message: "success",
+		UserId:  req.UserId,
+		Count:   len(records),
+	})
+}
+
 // --- gRPC Health Check Implementation ---
 
 func (srv *Services) Check(context.Context, *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
@@ -113,5 +168,7 @@ func (srv *Services) Watch(*grpc_health_v1.HealthCheckRequest, grpc_health_v1.He
 }
 
 func (srv *Services) Close() {
-	// cleanup resources if needed
+	if store.ActionStoreInstance != nil {
+		store.ActionStoreInstance.Close()
+	}
 }
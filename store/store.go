@@ -0,0 +1,322 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/zlog"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTTL is the default record lifetime in seconds (7 days).
+	DefaultTTL = 7 * 24 * 3600
+	// DefaultMaxRecords is the default number of records kept per user and action.
+	DefaultMaxRecords = 1000
+	// DefaultExposeAction is the default action name of recorded exposures.
+	DefaultExposeAction = "expose"
+	// DefaultQueueSize is the default size of the pending write buffer.
+	DefaultQueueSize = 4096
+
+	// sweepInterval defines how often expired users are removed from the database.
+	sweepInterval = time.Hour
+	// writeBatchSize caps the number of queued writes committed in one transaction.
+	writeBatchSize = 256
+)
+
+// actionsBucket is the bolt bucket holding the records of every user, keyed by user ID.
+var actionsBucket = []byte("actions")
+
+// Record is a single user action on an item.
+type Record struct {
+	Action    string `json:"action"` // action name, e.g. "expose" or "click"
+	Key       string `json:"key"`    // item key
+	Timestamp int64  `json:"ts"`     // UNIX timestamp in seconds
+}
+
+// write is a pending append of records for one user.
+type write struct {
+	userId  string
+	records []Record
+}
+
+// ActionStore persists per-user exposures and actions in an embedded bolt database,
+// so frequency control survives restarts without an external service.
+// Writes are queued and committed in batches by a background goroutine;
+// records older than the TTL are dropped on read, on write and by a periodic sweep.
+type ActionStore struct {
+	conf   config.ActionStoreConfig
+	db     *bolt.DB
+	queue  chan write
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewActionStore opens (or creates) the bolt database at conf.Path and starts the writer.
+//
+// @param conf Action store configuration.
+// @return ActionStore instance or error if the database cannot be opened.
+func NewActionStore(conf config.ActionStoreConfig) (*ActionStore, error) {
+	pStat := prome.NewStat("NewActionStore")
+	defer pStat.End()
+
+	if conf.TTL <= 0 {
+		conf.TTL = DefaultTTL
+	}
+	if conf.MaxRecords <= 0 {
+		conf.MaxRecords = DefaultMaxRecords
+	}
+	if conf.ExposeAction == "" {
+		conf.ExposeAction = DefaultExposeAction
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = DefaultQueueSize
+	}
+
+	db, err := bolt.Open(conf.Path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		pStat.MarkErr()
+		zlog.LOG.Error("ActionStore: failed to open database", zap.String("path", conf.Path), zap.Error(err))
+		return nil, fmt.Errorf("failed to open action store %s: %w", conf.Path, err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(actionsBucket)
+		return err
+	}); err != nil {
+		pStat.MarkErr()
+		db.Close()
+		return nil, fmt.Errorf("failed to create bucket: %w", err)
+	}
+
+	s := &ActionStore{
+		conf:   conf,
+		db:     db,
+		queue:  make(chan write, conf.QueueSize),
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+	go s.writeLoop()
+
+	zlog.LOG.Info("ActionStore: initialized successfully",
+		zap.String("path", conf.Path),
+		zap.Int("ttl", conf.TTL),
+		zap.Int("max_records", conf.MaxRecords))
+	return s, nil
+}
+
+// GetExposeAction returns the action name under which exposures are recorded.
+func (s *ActionStore) GetExposeAction() string {
+	return s.conf.ExposeAction
+}
+
+// Add queues records of a user for writing.
+// Records are dropped (and counted) when the write queue is full, so callers never block.
+func (s *ActionStore) Add(userId string, records ...Record) {
+	if userId == "" || len(records) == 0 {
+		return
+	}
+	select {
+	case s.queue <- write{userId: userId, records: records}:
+	default:
+		pStat := prome.NewStat("ActionStore.Add.QueueFull")
+		pStat.MarkErr()
+		pStat.End()
+		zlog.LOG.Warn("ActionStore.Add.QueueFull",
+			zap.String("user_id", userId),
+			zap.Int("dropped", len(records)))
+	}
+}
+
+// Get returns the user's unexpired records, ordered from oldest to newest.
+func (s *ActionStore) Get(userId string) ([]Record, error) {
+	pStat := prome.NewStat("ActionStore.Get")
+	defer pStat.End()
+
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(actionsBucket).Get([]byte(userId))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &records)
+	})
+	if err != nil {
+		pStat.MarkErr()
+		zlog.LOG.Error("ActionStore.Get.Error", zap.String("user_id", userId), zap.Error(err))
+		return nil, err
+	}
+
+	records = s.expire(records, time.Now().Unix())
+	pStat.SetCounter(len(records))
+	return records, nil
+}
+
+// Close stops the writer after flushing queued writes and closes the database.
+func (s *ActionStore) Close() {
+	close(s.stopCh)
+	<-s.doneCh
+	if err := s.db.Close(); err != nil {
+		zlog.LOG.Error("ActionStore: failed to close database", zap.Error(err))
+	}
+	zlog.LOG.Info("ActionStore: closed", zap.String("path", s.conf.Path))
+}
+
+// expire drops records older than the TTL and keeps at most MaxRecords of the newest per action,
+// so frequent exposures never evict rarer actions such as clicks.
+// records must be ordered from oldest to newest.
+func (s *ActionStore) expire(records []Record, now int64) []Record {
+	cutoff := now - int64(s.conf.TTL)
+	start := sort.Search(len(records), func(i int) bool {
+		return records[i].Timestamp >= cutoff
+	})
+	records = records[start:]
+
+	// Count from the newest, then compact the kept records in place keeping their order
+	counts := make(map[string]int, 4)
+	keep := make([]bool, len(records))
+	kept := 0
+	for i := len(records) - 1; i >= 0; i-- {
+		if counts[records[i].Action] < s.conf.MaxRecords {
+			counts[records[i].Action]++
+			keep[i] = true
+			kept++
+		}
+	}
+	if kept == len(records) {
+		return records
+	}
+	ret := records[:0]
+	for i, record := range records {
+		if keep[i] {
+			ret = append(ret, record)
+		}
+	}
+	return ret
+}
+
+// writeLoop commits queued writes in batches and periodically sweeps expired users.
+func (s *ActionStore) writeLoop() {
+	defer close(s.doneCh)
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case w := <-s.queue:
+			s.commit(s.drain(w))
+		case <-ticker.C:
+			s.sweep()
+		case <-s.stopCh:
+			for {
+				select {
+				case w := <-s.queue:
+					s.commit(s.drain(w))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// drain collects the given write and up to writeBatchSize-1 more queued writes.
+func (s *ActionStore) drain(first write) []write {
+	batch := make([]write, 0, writeBatchSize)
+	batch = append(batch, first)
+	for len(batch) < writeBatchSize {
+		select {
+		case w := <-s.queue:
+			batch = append(batch, w)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// commit appends a batch of writes in a single transaction.
+func (s *ActionStore) commit(batch []write) {
+	pStat := prome.NewStat("ActionStore.Commit")
+	defer pStat.End()
+
+	now := time.Now().Unix()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(actionsBucket)
+		for _, w := range batch {
+			var records []Record
+			if data := bucket.Get([]byte(w.userId)); data != nil {
+				if err := json.Unmarshal(data, &records); err != nil {
+					zlog.LOG.Warn("ActionStore.Commit.CorruptRecords", zap.String("user_id", w.userId), zap.Error(err))
+					records = nil
+				}
+			}
+
+			records = append(records, w.records...)
+			sort.SliceStable(records, func(i, j int) bool {
+				return records[i].Timestamp < records[j].Timestamp
+			})
+			records = s.expire(records, now)
+
+			data, err := json.Marshal(records)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(w.userId), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		pStat.MarkErr()
+		zlog.LOG.Error("ActionStore.Commit.Error", zap.Int("batch_size", len(batch)), zap.Error(err))
+		return
+	}
+	pStat.SetCounter(len(batch))
+}
+
+// sweep deletes users whose records have all expired.
+func (s *ActionStore) sweep() {
+	pStat := prome.NewStat("ActionStore.Sweep")
+	defer pStat.End()
+
+	cutoff := time.Now().Unix() - int64(s.conf.TTL)
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(actionsBucket)
+		// Deleting under the cursor may skip the next key, so delete after the scan
+		expired := make([][]byte, 0)
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var records []Record
+			if err := json.Unmarshal(v, &records); err == nil &&
+				len(records) > 0 && records[len(records)-1].Timestamp >= cutoff {
+				continue
+			}
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		pStat.MarkErr()
+		zlog.LOG.Error("ActionStore.Sweep.Error", zap.Error(err))
+		return
+	}
+	pStat.SetCounter(deleted)
+	zlog.LOG.Info("ActionStore.Sweep.Completed", zap.Int("deleted_users", deleted))
+}
+
+// ActionStoreInstance is the global action store, nil when the store is disabled.
+var ActionStoreInstance *ActionStore
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/uopensail/recgo-engine/config"
+	_ "github.com/uopensail/recgo-engine/internal/testlog"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestActionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.db")
+	conf := config.ActionStoreConfig{Path: path, TTL: 3600, MaxRecords: 2}
+
+	s, err := NewActionStore(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	s.Add("u1",
+		Record{Action: "click", Key: "expired", Timestamp: now - 7200},
+		Record{Action: "expose", Key: "a", Timestamp: now - 30},
+		Record{Action: "expose", Key: "b", Timestamp: now - 20},
+	)
+	s.Add("u1",
+		Record{Action: "click", Key: "c", Timestamp: now - 25},
+		Record{Action: "expose", Key: "d", Timestamp: now},
+	)
+	// Close flushes the queue, reopening checks the records survive a restart
+	s.Close()
+
+	s, err = NewActionStore(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	records, err := s.Get("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// At most 2 records per action: the oldest exposure goes, the click survives newer exposures
+	want := []string{"c", "b", "d"}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(records), len(want), records)
+	}
+	for i, key := range want {
+		if records[i].Key != key {
+			t.Errorf("record %d: got %s, want %s", i, records[i].Key, key)
+		}
+	}
+
+	if records, _ := s.Get("unknown"); len(records) != 0 {
+		t.Errorf("unknown user: got %d records", len(records))
+	}
+}
+
+func TestActionStoreSweep(t *testing.T) {
+	conf := config.ActionStoreConfig{Path: filepath.Join(t.TempDir(), "actions.db"), TTL: 3600}
+	s, err := NewActionStore(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Neighbouring expired users around a live one, written directly to skip the expiry on write
+	now := time.Now().Unix()
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(actionsBucket)
+		for i := 0; i < 10; i++ {
+			ts := now - 7200
+			if i == 5 {
+				ts = now
+			}
+			data, _ := json.Marshal([]Record{{Action: "click", Key: "a", Timestamp: ts}})
+			if err := bucket.Put([]byte(fmt.Sprintf("u%d", i)), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.sweep()
+
+	var left []string
+	s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(actionsBucket).ForEach(func(k, v []byte) error {
+			left = append(left, string(k))
+			return nil
+		})
+	})
+	if len(left) != 1 || left[0] != "u5" {
+		t.Errorf("got users %v after sweep, want [u5]", left)
+	}
+}
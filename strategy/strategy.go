@@ -2,11 +2,13 @@ package strategy
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/uopensail/recgo-engine/config"
 	"github.com/uopensail/recgo-engine/model"
 	"github.com/uopensail/recgo-engine/pipeline"
 	"github.com/uopensail/recgo-engine/recapi"
+	"github.com/uopensail/recgo-engine/store"
 	"github.com/uopensail/recgo-engine/userctx"
 	"github.com/uopensail/ulib/prome"
 	"github.com/uopensail/ulib/sample"
@@ -66,13 +68,15 @@ func (s *Strategy) runPipeline(uCtx *userctx.UserContext, p pipeline.IPipeline)
 
 	collection := p.Do(uCtx)
 	resp := &recapi.Response{
-		Code:     0,
-		Message:  "success",
-		TraceId:  uCtx.Request.TraceId,
-		UserId:   uCtx.Request.UserId,
-		Pipeline: p.GetName(),
-		Items:    make([]*recapi.ItemInfo, 0, len(collection)),
-		Count:    len(collection),
+		Code:         0,
+		Message:      "success",
+		TraceId:      uCtx.Request.TraceId,
+		UserId:       uCtx.Request.UserId,
+		Pipeline:     p.GetName(),
+		Items:        make([]*recapi.ItemInfo, 0, len(collection)),
+		Count:        len(collection),
+		Versions:     uCtx.Versions.Map(),
+		Degradations: uCtx.Degradations.Map(),
 	}
 
 	var fea sample.Feature
@@ -89,9 +93,26 @@ func (s *Strategy) runPipeline(uCtx *userctx.UserContext, p pipeline.IPipeline)
 		})
 	}
 
+	recordExposures(uCtx.Request.UserId, collection)
 	return resp
 }
 
+// recordExposures writes the returned items to the action store as exposures.
+// It is a no-op when the store is disabled.
+func recordExposures(userId string, collection model.Collection) {
+	if store.ActionStoreInstance == nil || len(collection) == 0 {
+		return
+	}
+
+	action := store.ActionStoreInstance.GetExposeAction()
+	now := time.Now().Unix()
+	records := make([]store.Record, 0, len(collection))
+	for _, entry := range collection {
+		records = append(records, store.Record{Action: action, Key: entry.Key, Timestamp: now})
+	}
+	store.ActionStoreInstance.Add(userId, records...)
+}
+
 // Feeds returns feed recommendations for the given user context.
 func (s *Strategy) Feeds(uCtx *userctx.UserContext) *recapi.Response {
 	pStat := prome.NewStat(fmt.Sprintf("Strategy.Feed.%s", uCtx.Request.Pipeline))
@@ -116,5 +137,23 @@ func (s *Strategy) Related(uCtx *userctx.UserContext) *recapi.Response {
 	return s.runPipeline(uCtx, nil)
 }
 
+// FeedsTimeouts returns the stage budgets of the named feeds pipeline.
+// Returns zero budgets if the pipeline is not found.
+func (s *Strategy) FeedsTimeouts(name string) model.TimeoutConfigure {
+	if p, ok := s.feeds[name]; ok {
+		return p.GetTimeouts()
+	}
+	return model.TimeoutConfigure{}
+}
+
+// RelatedTimeouts returns the stage budgets of the named related pipeline.
+// Returns zero budgets if the pipeline is not found.
+func (s *Strategy) RelatedTimeouts(name string) model.TimeoutConfigure {
+	if p, ok := s.related[name]; ok {
+		return p.GetTimeouts()
+	}
+	return model.TimeoutConfigure{}
+}
+
 // StrategyInstance is the global strategy singleton.
 var StrategyInstance *Strategy
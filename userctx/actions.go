@@ -0,0 +1,134 @@
+package userctx
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/uopensail/recgo-engine/store"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+const (
+	// userRecordKeyPrefix is the Redis (or feature storage) key prefix
+	// for storing user action records.
+	//
+	// The full key format before splitting is:
+	//   u_r_${action} -> "timestamp|itemKey"
+	//
+	// Example:
+	//   u_r_click -> "1670832000|item123"
+	//
+	// This original string contains both a UNIX timestamp and an item ID/key
+	// separated by actionSeparator ("|").
+	//
+	// During processing, this data is split into two separate fields:
+	//   u_r_${action}_ts  -> stores only timestamps (int64 array)
+	//   u_r_${action}_ids -> stores only item keys (string array)
+	//
+	// The splitting allows independent access to timestamps and item IDs,
+	// which improves processing speed and simplifies filtering logic.
+	userRecordKeyPrefix = "u_r_%s"
+
+	// idsKeySuffix is the suffix appended to the base action key
+	// to store only item IDs/keys after data splitting.
+	// Example: "u_r_click_ids"
+	idsKeySuffix = "_ids"
+
+	// timestampKeySuffix is the suffix appended to the base action key
+	// to store only timestamps after data splitting.
+	// Example: "u_r_click_ts"
+	timestampKeySuffix = "_ts"
+
+	// actionSeparator defines the delimiter between timestamp and itemKey
+	// in the original combined data format.
+	// Example original data: "1670832000|item123"
+	// After splitting by actionSeparator: ["1670832000", "item123"]
+	actionSeparator = "|"
+)
+
+// ErrNoActionRecords is returned when neither the user features nor the action store
+// carry records for an action.
+var ErrNoActionRecords = errors.New("no action records")
+
+// ActionKey returns the base feature key of the given action, e.g. "u_r_click".
+func ActionKey(action string) string {
+	return fmt.Sprintf(userRecordKeyPrefix, action)
+}
+
+// storedRecords lazily loads the user's records from the action store once per request.
+type storedRecords struct {
+	once    sync.Once
+	records []store.Record
+}
+
+// GetActionRecords returns the item keys and timestamps of the user's records for the given action.
+// Records come from the u_r_${action}_ids and u_r_${action}_ts features, followed by the records
+// of the engine-owned action store when it is enabled.
+// Both slices have the same length and are index aligned.
+// Returns ErrNoActionRecords if neither source has records for the action.
+func (uCtx *UserContext) GetActionRecords(action string) ([]string, []int64, error) {
+	itemKeys, timestamps, err := uCtx.getFeatureRecords(action)
+	if err != nil && !errors.Is(err, ErrNoActionRecords) {
+		return nil, nil, err
+	}
+
+	for _, record := range uCtx.getStoredRecords() {
+		if record.Action == action {
+			itemKeys = append(itemKeys, record.Key)
+			timestamps = append(timestamps, record.Timestamp)
+		}
+	}
+
+	if len(itemKeys) == 0 {
+		return nil, nil, ErrNoActionRecords
+	}
+	return itemKeys, timestamps, nil
+}
+
+// getFeatureRecords reads the records of the given action from the user features.
+// Returns ErrNoActionRecords if either feature is missing.
+func (uCtx *UserContext) getFeatureRecords(action string) ([]string, []int64, error) {
+	actionKey := ActionKey(action)
+
+	rawKeys := uCtx.Features.Get(actionKey + idsKeySuffix)
+	rawTimestamps := uCtx.Features.Get(actionKey + timestampKeySuffix)
+	if rawKeys == nil || rawTimestamps == nil {
+		return nil, nil, ErrNoActionRecords
+	}
+
+	itemKeys, err := rawKeys.GetStrings()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s%s: %w", actionKey, idsKeySuffix, err)
+	}
+	timestamps, err := rawTimestamps.GetInt64s()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s%s: %w", actionKey, timestampKeySuffix, err)
+	}
+	if len(itemKeys) != len(timestamps) {
+		return nil, nil, fmt.Errorf("%s: %d keys mismatch %d timestamps", actionKey, len(itemKeys), len(timestamps))
+	}
+
+	// Copy so appending stored records never writes into the feature's backing arrays
+	return append([]string(nil), itemKeys...), append([]int64(nil), timestamps...), nil
+}
+
+// getStoredRecords returns the user's records in the action store, loaded on first use.
+// Returns nil if the store is disabled or the user has no records.
+func (uCtx *UserContext) getStoredRecords() []store.Record {
+	if store.ActionStoreInstance == nil || uCtx.stored == nil {
+		return nil
+	}
+	uCtx.stored.once.Do(func() {
+		records, err := store.ActionStoreInstance.Get(uCtx.Request.UserId)
+		if err != nil {
+			zlog.LOG.Error("UserContext.GetStoredRecordsFailed",
+				zap.String("user_id", uCtx.Request.UserId),
+				zap.Error(err))
+			return
+		}
+		uCtx.stored.records = records
+	})
+	return uCtx.stored.records
+}
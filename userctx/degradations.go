@@ -0,0 +1,40 @@
+package userctx
+
+import "sync"
+
+// Degradations records why a stage served a request in degraded mode,
+// e.g. a ranker that fell back because its model service failed.
+// Stages may run in parallel, so all methods are safe for concurrent use.
+type Degradations struct {
+	mu   sync.Mutex
+	dict map[string]string // stage name -> degradation reason
+}
+
+// NewDegradations creates an empty Degradations recorder.
+func NewDegradations() *Degradations {
+	return &Degradations{
+		dict: make(map[string]string, 4),
+	}
+}
+
+// Set records the degradation reason of the named stage.
+func (d *Degradations) Set(name string, reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dict[name] = reason
+}
+
+// Map returns a copy of all recorded degradations.
+// Returns nil if nothing has been recorded.
+func (d *Degradations) Map() map[string]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.dict) == 0 {
+		return nil
+	}
+	ret := make(map[string]string, len(d.dict))
+	for name, reason := range d.dict {
+		ret[name] = reason
+	}
+	return ret
+}
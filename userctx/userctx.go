@@ -1,49 +1,41 @@
 package userctx
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"io"
-	"net/http"
 	"time"
 
 	"github.com/uopensail/recgo-engine/model"
 	"github.com/uopensail/recgo-engine/recapi"
 	"github.com/uopensail/recgo-engine/resources"
+	"github.com/uopensail/recgo-engine/userfeature"
 	"github.com/uopensail/ulib/prome"
 	"github.com/uopensail/ulib/sample"
 	"github.com/uopensail/ulib/zlog"
 	"go.uber.org/zap"
 )
 
-// Request defines the feature fetch request payload for user context creation.
-type Request struct {
-	UserId string `json:"user_id"`
-}
-
-// Response defines the structure of the feature fetch API response.
-type Response struct {
-	Code int                     `json:"code"`
-	Msg  string                  `json:"msg"`
-	Data *sample.MutableFeatures `json:"data"`
-}
-
 // UserContext holds all runtime information for recommendation processing.
-// It contains request info, loaded items, filter results, user features and related features (contextual items).
+// It contains request info, loaded items, filter results, user features, related features (contextual items),
+// the versions of the resources that served the request, the degradations it went through
+// and the user's records in the action store.
 type UserContext struct {
 	context.Context
-	Request  *recapi.Request
-	Items    *model.Items
-	Filter   model.IFilter
-	Features *sample.MutableFeatures
-	Related  *sample.ImmutableFeatures
+	Request      *recapi.Request
+	Items        *model.Items
+	Filter       model.IFilter
+	Features     *sample.MutableFeatures
+	Related      *sample.ImmutableFeatures
+	Versions     *Versions
+	Degradations *Degradations
+	stored       *storedRecords
 }
 
 // NewUserContext creates a UserContext from a base context and recommendation API request.
 // It loads item resources, fetches related item features if RelateId is provided,
 // merges request-level features into the context, and fetches remote user features if available.
-func NewUserContext(ctx context.Context, req *recapi.Request) *UserContext {
+// Derived features are computed last, so their expressions see request and context features.
+// featuresTimeout bounds the remote feature fetch; a non-positive value only inherits ctx's deadline.
+func NewUserContext(ctx context.Context, req *recapi.Request, featuresTimeout time.Duration) *UserContext {
 	pStat := prome.NewStat("NewUserContext")
 	defer pStat.End()
 
@@ -59,21 +51,21 @@ func NewUserContext(ctx context.Context, req *recapi.Request) *UserContext {
 	}
 
 	uCtx := UserContext{
-		Context:  ctx,
-		Request:  req,
-		Items:    items,
-		Filter:   nil,
-		Features: nil,
-		Related:  related,
+		Context:      ctx,
+		Request:      req,
+		Items:        items,
+		Filter:       nil,
+		Features:     nil,
+		Related:      related,
+		Versions:     NewVersions(),
+		Degradations: NewDegradations(),
+		stored:       &storedRecords{},
 	}
 
-	// Fetch remote user features
-	features := uCtx.fetchFeatures()
-	if features == nil {
-		zlog.LOG.Warn("UserContext.FetchFeaturesEmpty",
-			zap.String("user_id", req.UserId))
-		features = sample.NewMutableFeatures()
-	}
+	// Fetch remote user features within the feature budget
+	fCtx, cancel := uCtx.WithBudget(featuresTimeout)
+	features := fCtx.loadFeatures()
+	cancel()
 
 	// Merge request features and context features into the user feature set
 	merge := func(key string, feature sample.Feature) error {
@@ -82,6 +74,9 @@ func NewUserContext(ctx context.Context, req *recapi.Request) *UserContext {
 	}
 	uCtx.Request.Features.ForEach(merge)
 	uCtx.Request.Context.ForEach(merge)
+
+	// Compute derived features on top of the merged features, before any recall sees them
+	userfeature.DerivedInstance.Apply(features)
 	uCtx.Features = features
 
 	zlog.LOG.Debug("UserContext.Created",
@@ -91,71 +86,58 @@ func NewUserContext(ctx context.Context, req *recapi.Request) *UserContext {
 	return &uCtx
 }
 
-// fetchFeatures contacts the external user feature service to retrieve user features.
-// Returns nil if the request fails or the response is invalid.
-func (uCtx *UserContext) fetchFeatures() *sample.MutableFeatures {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-		},
-	}
+// loadFeatures fetches the user's features from the configured feature providers.
+// Skipped fetches, failures of every provider and users without features are counted separately
+// ("UserContext.Features.Skip", "UserContext.Features.Fail", "UserContext.Features.Empty");
+// in every case the returned features are non-nil.
+func (uCtx *UserContext) loadFeatures() *sample.MutableFeatures {
+	pStat := prome.NewStat("UserContext.Features")
+	defer pStat.End()
 
-	reqPayload := Request{
-		UserId: uCtx.Request.UserId,
-	}
-	data, err := json.Marshal(reqPayload)
-	if err != nil {
-		zlog.LOG.Error("UserContext.MarshalRequestFailed",
-			zap.String("user_id", uCtx.Request.UserId),
-			zap.Error(err))
-		return nil
+	providers := userfeature.ProvidersInstance
+	if providers.Len() == 0 || (providers.SkipProvided() && uCtx.Request.Features != nil && uCtx.Request.Features.Len() > 0) {
+		sStat := prome.NewStat("UserContext.Features.Skip")
+		sStat.End()
+		return sample.NewMutableFeatures()
 	}
 
-	// TODO: Replace "/user" with a full URL or configurable endpoint
-	req, err := http.NewRequest("POST", "/user", bytes.NewBuffer(data))
+	features, err := providers.Fetch(uCtx, uCtx.Request.UserId)
 	if err != nil {
-		zlog.LOG.Error("UserContext.CreateHTTPRequestFailed",
+		pStat.MarkErr()
+		fStat := prome.NewStat("UserContext.Features.Fail")
+		fStat.MarkErr()
+		fStat.End()
+		zlog.LOG.Error("UserContext.FetchFeaturesFailed",
 			zap.String("user_id", uCtx.Request.UserId),
 			zap.Error(err))
-		return nil
+		return features
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	response, err := client.Do(req)
-	if err != nil {
-		zlog.LOG.Error("UserContext.FetchFeatureHTTPRequestFailed",
-			zap.String("user_id", uCtx.Request.UserId),
-			zap.Error(err))
-		return nil
+	if features.Len() == 0 {
+		eStat := prome.NewStat("UserContext.Features.Empty")
+		eStat.End()
+		zlog.LOG.Debug("UserContext.FetchFeaturesEmpty",
+			zap.String("user_id", uCtx.Request.UserId))
 	}
-	defer response.Body.Close()
+	pStat.SetCounter(features.Len())
+	return features
+}
 
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
-		zlog.LOG.Error("UserContext.ReadResponseBodyFailed",
-			zap.String("user_id", uCtx.Request.UserId),
-			zap.Error(err))
-		return nil
-	}
+// WithContext returns a shallow copy of uCtx whose Context is replaced by ctx.
+// All other fields are shared with uCtx.
+func (uCtx *UserContext) WithContext(ctx context.Context) *UserContext {
+	ret := *uCtx
+	ret.Context = ctx
+	return &ret
+}
 
-	resp := Response{}
-	if err := json.Unmarshal(body, &resp); err != nil {
-		zlog.LOG.Error("UserContext.UnmarshalResponseFailed",
-			zap.String("user_id", uCtx.Request.UserId),
-			zap.Error(err))
-		return nil
-	}
-	if resp.Data == nil {
-		zlog.LOG.Warn("UserContext.EmptyFeatureResponse",
-			zap.String("user_id", uCtx.Request.UserId),
-			zap.Int("code", resp.Code),
-			zap.String("msg", resp.Msg))
+// WithBudget returns a copy of uCtx whose Context is bounded by budget,
+// together with the cancel function releasing it.
+// A non-positive budget only inherits the deadline of uCtx's own Context.
+func (uCtx *UserContext) WithBudget(budget time.Duration) (*UserContext, context.CancelFunc) {
+	if budget <= 0 {
+		ctx, cancel := context.WithCancel(uCtx.Context)
+		return uCtx.WithContext(ctx), cancel
 	}
-
-	return resp.Data
+	ctx, cancel := context.WithTimeout(uCtx.Context, budget)
+	return uCtx.WithContext(ctx), cancel
 }
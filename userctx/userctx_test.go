@@ -0,0 +1,74 @@
+package userctx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/uopensail/recgo-engine/config"
+	_ "github.com/uopensail/recgo-engine/internal/testlog"
+	"github.com/uopensail/recgo-engine/outbound"
+	"github.com/uopensail/recgo-engine/recapi"
+	"github.com/uopensail/recgo-engine/userfeature"
+	"github.com/uopensail/ulib/sample"
+)
+
+func TestMain(m *testing.M) {
+	outbound.ClientManagerInstance = outbound.NewClientManager(nil)
+	os.Exit(m.Run())
+}
+
+func TestLoadFeatures(t *testing.T) {
+	var calls int32
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var req userfeature.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		requested = req.Features
+		if req.UserId == "broken" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(userfeature.Response{Code: 0})
+	}))
+	defer server.Close()
+
+	userfeature.ProvidersInstance = userfeature.NewProviders(config.UserFeatureConfig{
+		Providers: []config.FeatureProviderConfig{{
+			Name:     "service",
+			Type:     userfeature.ProviderTypeHTTP,
+			URL:      server.URL,
+			Timeout:  100,
+			Features: []string{"u_age", "u_r_click_ids"},
+		}},
+		SkipProvided: true,
+	})
+	defer func() { userfeature.ProvidersInstance = nil }()
+
+	newCtx := func(userId string, provided *sample.MutableFeatures) *UserContext {
+		return &UserContext{Context: context.Background(), Request: &recapi.Request{UserId: userId, Features: provided}}
+	}
+
+	// A user without features and a failing service both yield empty, non-nil features
+	for _, userId := range []string{"u1", "broken"} {
+		if features := newCtx(userId, nil).loadFeatures(); features == nil || features.Len() != 0 {
+			t.Errorf("%s: got %v, want empty features", userId, features)
+		}
+	}
+	if calls != 2 || len(requested) != 2 || requested[1] != "u_r_click_ids" {
+		t.Errorf("got %d calls requesting %v", calls, requested)
+	}
+
+	// Features supplied by the caller skip the fetch
+	provided := sample.NewMutableFeatures()
+	provided.Set("u_age", &sample.Int64{Value: 30})
+	newCtx("u1", provided).loadFeatures()
+	if calls != 2 {
+		t.Errorf("fetched although the request carries features")
+	}
+}
@@ -0,0 +1,39 @@
+package userctx
+
+import "sync"
+
+// Versions records which version of each hot-reloaded resource served a request.
+// Recalls run in parallel, so all methods are safe for concurrent use.
+type Versions struct {
+	mu   sync.Mutex
+	dict map[string]string // resource name -> version (source path of the loaded resource)
+}
+
+// NewVersions creates an empty Versions recorder.
+func NewVersions() *Versions {
+	return &Versions{
+		dict: make(map[string]string, 8),
+	}
+}
+
+// Set records the version of the named resource.
+func (v *Versions) Set(name string, version string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.dict[name] = version
+}
+
+// Map returns a copy of all recorded versions.
+// Returns nil if nothing has been recorded.
+func (v *Versions) Map() map[string]string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.dict) == 0 {
+		return nil
+	}
+	ret := make(map[string]string, len(v.dict))
+	for name, version := range v.dict {
+		ret[name] = version
+	}
+	return ret
+}
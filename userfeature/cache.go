@@ -0,0 +1,140 @@
+package userfeature
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/sample"
+)
+
+// cacheEntry is a cached fetch result.
+type cacheEntry struct {
+	userId   string
+	features *sample.MutableFeatures
+	expireAt time.Time
+}
+
+// call is a fetch in flight, shared by concurrent requests for the same user.
+type call struct {
+	done     chan struct{}
+	features *sample.MutableFeatures
+	complete bool
+	err      error
+	canceled bool // the leader's ctx was done when the fetch returned
+}
+
+// fetchFunc fetches the features of a user, reporting whether the result may be cached.
+type fetchFunc func(ctx context.Context, userId string) (*sample.MutableFeatures, bool, error)
+
+// cache is an LRU cache of user features with a TTL.
+// Concurrent misses for the same user are coalesced into one fetch; failed or incomplete fetches
+// are not cached.
+// Callers always get a copy, so they may modify the returned features.
+type cache struct {
+	mu       sync.Mutex
+	size     int
+	ttl      time.Duration
+	lru      *list.List               // front is the most recently used
+	dict     map[string]*list.Element // user id -> element holding a *cacheEntry
+	inflight map[string]*call
+	now      func() time.Time
+}
+
+// newCache creates a cache holding up to size users for ttl.
+func newCache(size int, ttl time.Duration) *cache {
+	return &cache{
+		size:     size,
+		ttl:      ttl,
+		lru:      list.New(),
+		dict:     make(map[string]*list.Element, size),
+		inflight: make(map[string]*call),
+		now:      time.Now,
+	}
+}
+
+// get returns the cached features of userId, or fetches them once for all concurrent callers.
+// The fetch runs under the ctx of the caller that started it; if that ctx was canceled or
+// ran out before the fetch returned, waiters whose own ctx is still alive fetch again rather
+// than inherit its error or partial features. A caller whose ctx is done stops waiting.
+func (c *cache) get(ctx context.Context, userId string, fetch fetchFunc) (*sample.MutableFeatures, error) {
+	c.mu.Lock()
+	if elem, ok := c.dict[userId]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if c.now().Before(entry.expireAt) {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			hStat := prome.NewStat("UserFeature.Cache.Hit")
+			hStat.End()
+			return copyFeatures(entry.features), nil
+		}
+		c.lru.Remove(elem)
+		delete(c.dict, userId)
+	}
+
+	mStat := prome.NewStat("UserFeature.Cache.Miss")
+	mStat.End()
+
+	if cl, ok := c.inflight[userId]; ok {
+		c.mu.Unlock()
+		sStat := prome.NewStat("UserFeature.Cache.Shared")
+		defer sStat.End()
+		select {
+		case <-cl.done:
+			if cl.canceled && ctx.Err() == nil {
+				return c.get(ctx, userId, fetch)
+			}
+			return copyFeatures(cl.features), cl.err
+		case <-ctx.Done():
+			sStat.MarkErr()
+			return sample.NewMutableFeatures(), ctx.Err()
+		}
+	}
+	cl := &call{done: make(chan struct{})}
+	c.inflight[userId] = cl
+	c.mu.Unlock()
+
+	cl.features, cl.complete, cl.err = fetch(ctx, userId)
+	cl.canceled = ctx.Err() != nil
+
+	// Leave inflight before waking the waiters, so a waiter fetching again starts a new call
+	c.mu.Lock()
+	delete(c.inflight, userId)
+	if cl.err == nil && cl.complete {
+		c.add(userId, cl.features)
+	}
+	c.mu.Unlock()
+	close(cl.done)
+	return copyFeatures(cl.features), cl.err
+}
+
+// add stores features and evicts the least recently used users beyond size, the caller holds mu.
+func (c *cache) add(userId string, features *sample.MutableFeatures) {
+	entry := &cacheEntry{userId: userId, features: features, expireAt: c.now().Add(c.ttl)}
+	if elem, ok := c.dict[userId]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.dict[userId] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.dict, oldest.Value.(*cacheEntry).userId)
+	}
+}
+
+// copyFeatures returns a shallow copy of features, never nil.
+func copyFeatures(features *sample.MutableFeatures) *sample.MutableFeatures {
+	ret := sample.NewMutableFeatures()
+	if features == nil {
+		return ret
+	}
+	features.ForEach(func(key string, feature sample.Feature) error {
+		ret.Set(key, feature)
+		return nil
+	})
+	return ret
+}
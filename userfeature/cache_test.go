@@ -0,0 +1,143 @@
+package userfeature
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uopensail/ulib/sample"
+)
+
+func TestCache(t *testing.T) {
+	var calls int32
+	complete := true
+	fetch := func(ctx context.Context, userId string) (*sample.MutableFeatures, bool, error) {
+		atomic.AddInt32(&calls, 1)
+		features := sample.NewMutableFeatures()
+		features.Set("user", &sample.String{Value: userId})
+		return features, complete, nil
+	}
+
+	now := time.Now()
+	c := newCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+	get := func(userId string) {
+		features, err := c.get(context.Background(), userId, fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Callers own their copy
+		features.Set("request", &sample.String{Value: "x"})
+	}
+
+	get("u1")
+	get("u1")
+	if calls != 1 {
+		t.Fatalf("got %d fetches, want 1", calls)
+	}
+	if cached := c.dict["u1"].Value.(*cacheEntry).features; cached.Get("request") != nil {
+		t.Error("caller modified the cached features")
+	}
+
+	// u1 is evicted as the least recently used user
+	get("u2")
+	get("u3")
+	get("u1")
+	if calls != 4 {
+		t.Errorf("got %d fetches after eviction, want 4", calls)
+	}
+
+	// Expired entries are fetched again, incomplete results are not cached
+	now = now.Add(2 * time.Minute)
+	complete = false
+	get("u1")
+	get("u1")
+	if calls != 6 {
+		t.Errorf("got %d fetches after expiry, want 6", calls)
+	}
+}
+
+func TestCacheCoalescing(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context, userId string) (*sample.MutableFeatures, bool, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return sample.NewMutableFeatures(), true, nil
+	}
+
+	c := newCache(10, time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.get(context.Background(), "u1", fetch)
+		}()
+	}
+	// Let every caller join the fetch in flight before releasing it
+	for {
+		c.mu.Lock()
+		started := c.inflight["u1"] != nil
+		c.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("got %d fetches, want 1", calls)
+	}
+}
+
+func TestCacheLeaderCanceled(t *testing.T) {
+	var calls int32
+	fetch := func(ctx context.Context, userId string) (*sample.MutableFeatures, bool, error) {
+		features := sample.NewMutableFeatures()
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// The first fetch is cut short by its caller
+			<-ctx.Done()
+			return features, false, nil
+		}
+		features.Set("user", &sample.String{Value: userId})
+		return features, true, nil
+	}
+
+	c := newCache(10, time.Minute)
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	go c.get(leaderCtx, "u1", fetch)
+	for {
+		c.mu.Lock()
+		started := c.inflight["u1"] != nil
+		c.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The waiter joins the leader's fetch, then fetches again once the leader is canceled
+	result := make(chan *sample.MutableFeatures)
+	go func() {
+		features, err := c.get(context.Background(), "u1", fetch)
+		if err != nil {
+			t.Error(err)
+		}
+		result <- features
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	if features := <-result; features.Get("user") == nil {
+		t.Error("waiter got the canceled leader's partial features")
+	}
+	if calls != 2 {
+		t.Errorf("got %d fetches, want 2", calls)
+	}
+}
@@ -0,0 +1,82 @@
+package userfeature
+
+import (
+	"fmt"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/recgo-engine/program"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/sample"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// derivation is a compiled derived feature.
+type derivation struct {
+	name    string
+	program *program.Program
+}
+
+// Derived computes the derived features declared in AppConfig.DerivedFeatures.
+type Derived struct {
+	derivations []*derivation
+}
+
+// NewDerived compiles the derived feature expressions.
+// Panics if a name is empty or an expression cannot be parsed.
+func NewDerived(confs []config.DerivedFeatureConfig) *Derived {
+	pStat := prome.NewStat("UserFeature.NewDerived")
+	defer pStat.End()
+
+	derivations := make([]*derivation, 0, len(confs))
+	for _, conf := range confs {
+		if conf.Name == "" {
+			panic(fmt.Errorf("derived feature with empty name: %s", conf.Expr))
+		}
+		prog, err := program.NewProgram(conf.Expr)
+		if err != nil {
+			zlog.LOG.Error("UserFeature.NewDerived program create error",
+				zap.String("name", conf.Name),
+				zap.Error(err))
+			panic(err)
+		}
+		derivations = append(derivations, &derivation{name: conf.Name, program: prog})
+	}
+	return &Derived{derivations: derivations}
+}
+
+// Apply evaluates every derived feature in order and writes the results into features.
+// A derived feature whose expression fails (e.g. an input feature is missing) or yields
+// an unsupported type is left unset and counted ("UserFeature.Derived.<name>").
+// Nil Derived is a no-op.
+func (d *Derived) Apply(features *sample.MutableFeatures) {
+	if d == nil {
+		return
+	}
+	for _, der := range d.derivations {
+		dStat := prome.NewStat(fmt.Sprintf("UserFeature.Derived.%s", der.name))
+		value, err := der.program.Eval(features)
+		if err != nil {
+			dStat.MarkErr()
+			dStat.End()
+			zlog.LOG.Debug("UserFeature.Derived.EvalFailed",
+				zap.String("name", der.name),
+				zap.Error(err))
+			continue
+		}
+		feature, ok := program.ToFeature(value)
+		if !ok {
+			dStat.MarkErr()
+			dStat.End()
+			zlog.LOG.Debug("UserFeature.Derived.UnsupportedType",
+				zap.String("name", der.name),
+				zap.String("type", fmt.Sprintf("%T", value)))
+			continue
+		}
+		features.Set(der.name, feature)
+		dStat.End()
+	}
+}
+
+// DerivedInstance is the global set of derived features, nil when none are configured.
+var DerivedInstance *Derived
@@ -0,0 +1,36 @@
+package userfeature
+
+import (
+	"testing"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/ulib/sample"
+)
+
+func TestDerived(t *testing.T) {
+	derived := NewDerived([]config.DerivedFeatureConfig{
+		{Name: "u_age_group", Expr: `u_age >= 30 ? "adult" : "young"`},
+		{Name: "u_activity", Expr: "len(u_r_click_ids)"},
+		{Name: "u_active", Expr: "u_activity > 2"},
+		{Name: "u_shares", Expr: "len(u_r_share_ids)"},
+	})
+
+	features := sample.NewMutableFeatures()
+	features.Set("u_age", &sample.Int64{Value: 37})
+	features.Set("u_r_click_ids", &sample.Strings{Value: []string{"a", "b", "c"}})
+	derived.Apply(features)
+
+	if v, _ := features.Get("u_age_group").GetString(); v != "adult" {
+		t.Errorf("u_age_group: got %q", v)
+	}
+	if v, _ := features.Get("u_activity").GetInt64(); v != 3 {
+		t.Errorf("u_activity: got %d", v)
+	}
+	if v, _ := features.Get("u_active").GetInt64(); v != 1 {
+		t.Errorf("u_active: got %d", v)
+	}
+	// Missing inputs leave the derived feature unset
+	if features.Get("u_shares") != nil {
+		t.Error("u_shares should be unset")
+	}
+}
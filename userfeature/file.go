@@ -0,0 +1,34 @@
+package userfeature
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/recgo-engine/resources"
+	"github.com/uopensail/ulib/sample"
+)
+
+// FileProvider looks users up in a profile table loaded by the resource manager.
+// The table is resolved on every fetch, so a reloaded file takes effect without downtime.
+type FileProvider struct {
+	conf config.FeatureProviderConfig
+}
+
+// NewFileProvider creates a FileProvider.
+// Panics if conf.Profile is not declared in AppConfig.Profiles.
+func NewFileProvider(conf config.FeatureProviderConfig) *FileProvider {
+	if !config.AppConfigInstance.HasProfile(conf.Profile) {
+		panic(fmt.Errorf("user feature provider %s: profile %s is not declared", conf.Name, conf.Profile))
+	}
+	return &FileProvider{conf: conf}
+}
+
+// Fetch returns the user's row of the profile table.
+func (p *FileProvider) Fetch(ctx context.Context, userId string) (*sample.MutableFeatures, error) {
+	profiles := resources.ResourceManagerInstance.GetProfiles(p.conf.Profile)
+	if profiles == nil {
+		return nil, fmt.Errorf("profile %s not loaded", p.conf.Profile)
+	}
+	return profiles.Get(userId)
+}
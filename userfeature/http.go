@@ -0,0 +1,92 @@
+package userfeature
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/recgo-engine/outbound"
+	"github.com/uopensail/ulib/sample"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// Request defines the payload sent to a user feature service.
+// Features lists the requested feature names, empty for all of the user's features.
+type Request struct {
+	UserId   string   `json:"user_id"`
+	Features []string `json:"features,omitempty"`
+}
+
+// Response defines the structure of the feature service response.
+type Response struct {
+	Code int                     `json:"code"`
+	Msg  string                  `json:"msg"`
+	Data *sample.MutableFeatures `json:"data"`
+}
+
+// HTTPProvider fetches user features from a remote feature service.
+type HTTPProvider struct {
+	conf   config.FeatureProviderConfig
+	client *outbound.Client // shared outbound client
+}
+
+// NewHTTPProvider creates an HTTPProvider using the outbound client named by conf.Client.
+// Panics if the URL is empty or the client is unknown.
+func NewHTTPProvider(conf config.FeatureProviderConfig) *HTTPProvider {
+	if conf.URL == "" {
+		panic(fmt.Errorf("user feature provider %s: empty url", conf.Name))
+	}
+	client := outbound.ClientManagerInstance.Get(conf.Client)
+	if client == nil {
+		zlog.LOG.Error("UserFeature.NewHTTPProvider.UnknownClient",
+			zap.String("name", conf.Name),
+			zap.String("client", conf.Client))
+		panic(fmt.Errorf("user feature provider %s: unknown client %s", conf.Name, conf.Client))
+	}
+	return &HTTPProvider{conf: conf, client: client}
+}
+
+// Fetch posts the user id and the requested feature names to the feature service.
+// Returns an error if the request fails or the response is invalid.
+func (p *HTTPProvider) Fetch(ctx context.Context, userId string) (*sample.MutableFeatures, error) {
+	data, err := json.Marshal(Request{UserId: userId, Features: p.conf.Features})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.conf.URL, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	response, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non-OK status: %s", response.Status)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+
+	resp := Response{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal response: %w", err)
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("service error code %d: %s", resp.Code, resp.Msg)
+	}
+	return resp.Data, nil
+}
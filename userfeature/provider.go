@@ -0,0 +1,177 @@
+package userfeature
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/ulib/prome"
+	"github.com/uopensail/ulib/sample"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+// Provider type constants.
+const (
+	ProviderTypeHTTP  = "http"  // Remote feature service
+	ProviderTypeRedis = "redis" // Redis-protocol key-value store
+	ProviderTypeFile  = "file"  // Profile table loaded by the resource manager
+)
+
+// Provider fetches the features of a user from one source.
+// A user unknown to the source yields nil features and no error.
+type Provider interface {
+	Fetch(ctx context.Context, userId string) (*sample.MutableFeatures, error)
+}
+
+// source is a configured provider.
+type source struct {
+	conf     config.FeatureProviderConfig
+	provider Provider
+}
+
+// Providers assembles user features from several providers fetched concurrently.
+type Providers struct {
+	conf    config.UserFeatureConfig
+	sources []*source // ascending priority, later sources override earlier ones
+	cache   *cache    // merged features by user, nil without cache
+}
+
+// NewProviders creates the providers declared in conf, see config.UserFeatureConfig.GetProviders.
+// Panics if a provider has an unknown type or an invalid configuration.
+func NewProviders(conf config.UserFeatureConfig) *Providers {
+	pStat := prome.NewStat("UserFeature.NewProviders")
+	defer pStat.End()
+
+	confs := conf.GetProviders()
+	sources := make([]*source, 0, len(confs))
+	for _, pConf := range confs {
+		var provider Provider
+		switch pConf.Type {
+		case ProviderTypeHTTP:
+			provider = NewHTTPProvider(pConf)
+		case ProviderTypeRedis:
+			provider = NewRedisProvider(pConf)
+		case ProviderTypeFile:
+			provider = NewFileProvider(pConf)
+		default:
+			zlog.LOG.Error("UserFeature.NewProviders.UnknownType",
+				zap.String("name", pConf.Name),
+				zap.String("type", pConf.Type))
+			panic(fmt.Errorf("user feature provider %s: unknown type %s", pConf.Name, pConf.Type))
+		}
+		sources = append(sources, &source{conf: pConf, provider: provider})
+	}
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].conf.Priority < sources[j].conf.Priority
+	})
+
+	providers := &Providers{conf: conf, sources: sources}
+	if conf.CacheSize > 0 && conf.CacheTTL > 0 {
+		providers.cache = newCache(conf.CacheSize, time.Duration(conf.CacheTTL)*time.Second)
+	}
+	return providers
+}
+
+// Len returns the number of providers, 0 for nil Providers.
+func (p *Providers) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.sources)
+}
+
+// SkipProvided reports whether requests carrying features skip the fetch.
+func (p *Providers) SkipProvided() bool {
+	return p != nil && p.conf.SkipProvided
+}
+
+// Fetch returns the user's features, served from the cache when enabled.
+// The returned features are never nil and belong to the caller;
+// the error is non-nil only if every provider failed.
+func (p *Providers) Fetch(ctx context.Context, userId string) (*sample.MutableFeatures, error) {
+	if p.Len() == 0 {
+		return sample.NewMutableFeatures(), nil
+	}
+	if p.cache != nil {
+		return p.cache.get(ctx, userId, p.fetch)
+	}
+	features, _, err := p.fetch(ctx, userId)
+	return features, err
+}
+
+// fetch queries every provider concurrently, each within its own timeout, and merges the results:
+// feature names get the provider's prefix, and on conflicts the higher priority provider wins.
+// A failed provider is logged and counted ("UserFeature.<name>.Fetch"), the others still contribute.
+// complete reports whether every provider answered, i.e. whether the result may be cached.
+func (p *Providers) fetch(ctx context.Context, userId string) (merged *sample.MutableFeatures, complete bool, err error) {
+	merged = sample.NewMutableFeatures()
+
+	results := make([]*sample.MutableFeatures, len(p.sources))
+	errs := make([]error, len(p.sources))
+	var wg sync.WaitGroup
+	for i, src := range p.sources {
+		wg.Add(1)
+		go func(i int, src *source) {
+			defer wg.Done()
+			results[i], errs[i] = src.fetch(ctx, userId)
+		}(i, src)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, src := range p.sources {
+		if errs[i] != nil {
+			failed++
+			continue
+		}
+		if results[i] == nil {
+			continue
+		}
+		prefix := src.conf.Prefix
+		results[i].ForEach(func(key string, feature sample.Feature) error {
+			merged.Set(prefix+key, feature)
+			return nil
+		})
+	}
+	if failed == len(p.sources) {
+		return merged, false, errors.Join(errs...)
+	}
+	return merged, failed == 0, nil
+}
+
+// fetch queries the provider within its timeout and records the outcome.
+func (s *source) fetch(ctx context.Context, userId string) (*sample.MutableFeatures, error) {
+	pStat := prome.NewStat(fmt.Sprintf("UserFeature.%s.Fetch", s.conf.Name))
+	defer pStat.End()
+
+	if timeout := s.conf.FetchTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	features, err := s.provider.Fetch(ctx, userId)
+	if err != nil {
+		pStat.MarkErr()
+		zlog.LOG.Warn("UserFeature.FetchFailed",
+			zap.String("provider", s.conf.Name),
+			zap.String("user_id", userId),
+			zap.Error(err))
+		return nil, fmt.Errorf("provider %s: %w", s.conf.Name, err)
+	}
+	if features == nil || features.Len() == 0 {
+		eStat := prome.NewStat(fmt.Sprintf("UserFeature.%s.Empty", s.conf.Name))
+		eStat.End()
+		return nil, nil
+	}
+	pStat.SetCounter(features.Len())
+	return features, nil
+}
+
+// ProvidersInstance is the global set of user feature providers, nil when none are configured.
+var ProvidersInstance *Providers
@@ -0,0 +1,161 @@
+package userfeature
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/uopensail/recgo-engine/config"
+	_ "github.com/uopensail/recgo-engine/internal/testlog"
+	"github.com/uopensail/recgo-engine/outbound"
+	"github.com/uopensail/ulib/sample"
+)
+
+// standIn is a minimal Redis-protocol server answering AUTH, SELECT and GET from a map.
+type standIn struct {
+	listener net.Listener
+	mu       sync.Mutex
+	data     map[string]string
+	commands []string
+}
+
+func newStandIn(t *testing.T, data map[string]string) *standIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &standIn{listener: listener, data: data}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *standIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		c := &redisConn{conn: conn, reader: reader}
+		reply, err := c.readReply()
+		if err != nil {
+			return
+		}
+		args := make([]string, 0, 2)
+		for _, arg := range reply.([]any) {
+			args = append(args, arg.(string))
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		value, ok := s.data[args[len(args)-1]]
+		s.mu.Unlock()
+
+		switch {
+		case args[0] != "GET":
+			fmt.Fprint(conn, "+OK\r\n")
+		case ok:
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+		default:
+			fmt.Fprint(conn, "$-1\r\n")
+		}
+	}
+}
+
+func TestRedisProvider(t *testing.T) {
+	server := newStandIn(t, map[string]string{"profile:u1": `{}`})
+	provider := NewRedisProvider(config.FeatureProviderConfig{
+		Name:      "kv",
+		Addr:      server.listener.Addr().String(),
+		Password:  "secret",
+		DB:        2,
+		KeyPrefix: "profile:",
+	})
+
+	if features, err := provider.Fetch(context.Background(), "u1"); err != nil || features == nil {
+		t.Fatalf("u1: got %v, %v", features, err)
+	}
+	if features, err := provider.Fetch(context.Background(), "u2"); err != nil || features != nil {
+		t.Fatalf("u2: got %v, %v, want no features", features, err)
+	}
+
+	// The connection is authenticated once and reused
+	want := []string{"AUTH secret", "SELECT 2", "GET profile:u1", "GET profile:u2"}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if strings.Join(server.commands, ",") != strings.Join(want, ",") {
+		t.Errorf("got commands %v, want %v", server.commands, want)
+	}
+}
+
+// staticProvider returns fixed features or a fixed error.
+type staticProvider struct {
+	features map[string]string
+	err      error
+}
+
+func (p *staticProvider) Fetch(ctx context.Context, userId string) (*sample.MutableFeatures, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	features := sample.NewMutableFeatures()
+	for key, value := range p.features {
+		features.Set(key, &sample.String{Value: value})
+	}
+	return features, nil
+}
+
+func TestProvidersMerge(t *testing.T) {
+	providers := &Providers{sources: []*source{
+		{conf: config.FeatureProviderConfig{Name: "daily", Priority: 0}, provider: &staticProvider{features: map[string]string{"city": "old", "age": "30"}}},
+		{conf: config.FeatureProviderConfig{Name: "broken", Priority: 1}, provider: &staticProvider{err: errors.New("down")}},
+		{conf: config.FeatureProviderConfig{Name: "realtime", Priority: 2}, provider: &staticProvider{features: map[string]string{"city": "new"}}},
+		{conf: config.FeatureProviderConfig{Name: "kv", Prefix: "kv_", Priority: 3}, provider: &staticProvider{features: map[string]string{"age": "31"}}},
+	}}
+
+	features, err := providers.Fetch(context.Background(), "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"city": "new", "age": "30", "kv_age": "31"}
+	if features.Len() != len(want) {
+		t.Errorf("got %d features, want %d", features.Len(), len(want))
+	}
+	for key, value := range want {
+		if got, _ := features.Get(key).GetString(); got != value {
+			t.Errorf("%s: got %s, want %s", key, got, value)
+		}
+	}
+
+	// Only a failure of every provider is an error
+	providers.sources = providers.sources[1:2]
+	if _, err := providers.Fetch(context.Background(), "u1"); err == nil {
+		t.Error("expected an error when every provider fails")
+	}
+}
+
+func TestProvidersDefaultService(t *testing.T) {
+	outbound.ClientManagerInstance = outbound.NewClientManager(nil)
+	providers := NewProviders(config.UserFeatureConfig{
+		URL:       "http://features",
+		Timeout:   20,
+		Providers: []config.FeatureProviderConfig{{Name: "kv", Type: ProviderTypeRedis, Addr: "127.0.0.1:6379", Priority: 1}},
+	})
+	if providers.Len() != 2 {
+		t.Fatalf("got %d providers, want 2", providers.Len())
+	}
+	if conf := providers.sources[0].conf; conf.Name != config.DefaultFeatureProvider || conf.Type != ProviderTypeHTTP || conf.Timeout != 20 {
+		t.Errorf("unexpected default provider %+v", conf)
+	}
+}
@@ -0,0 +1,227 @@
+package userfeature
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/uopensail/recgo-engine/config"
+	"github.com/uopensail/ulib/sample"
+	"github.com/uopensail/ulib/zlog"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultRedisPoolSize is the number of idle connections kept when PoolSize is not set.
+	defaultRedisPoolSize = 16
+
+	// defaultRedisTimeout bounds a command whose context has no deadline.
+	defaultRedisTimeout = time.Second
+)
+
+// redisError is an error reply of the server; the connection stays usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// RedisProvider reads user features from a Redis-protocol key-value store.
+// The value of KeyPrefix+user_id is a JSON feature map in the same format as item features.
+// It speaks the RESP protocol directly over a small connection pool.
+type RedisProvider struct {
+	conf   config.FeatureProviderConfig
+	pool   chan *redisConn // idle connections
+	dialer net.Dialer
+}
+
+// redisConn is a connection with its buffered reader.
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisProvider creates a RedisProvider. Connections are dialed lazily.
+// Panics if conf.Addr is empty.
+func NewRedisProvider(conf config.FeatureProviderConfig) *RedisProvider {
+	if conf.Addr == "" {
+		panic(fmt.Errorf("user feature provider %s: empty addr", conf.Name))
+	}
+	poolSize := conf.PoolSize
+	if poolSize <= 0 {
+		poolSize = defaultRedisPoolSize
+	}
+	return &RedisProvider{
+		conf: conf,
+		pool: make(chan *redisConn, poolSize),
+	}
+}
+
+// Fetch gets the user's feature map. A missing key yields nil features and no error.
+func (p *RedisProvider) Fetch(ctx context.Context, userId string) (*sample.MutableFeatures, error) {
+	reply, err := p.do(ctx, "GET", p.conf.KeyPrefix+userId)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, nil
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply type %T", reply)
+	}
+
+	features := sample.NewMutableFeatures()
+	if err := sonic.UnmarshalString(data, features); err != nil {
+		return nil, fmt.Errorf("unmarshal features: %w", err)
+	}
+	return features, nil
+}
+
+// do runs a command on a pooled connection within ctx's deadline.
+// Connections that saw a network or protocol error are closed instead of pooled.
+func (p *RedisProvider) do(ctx context.Context, args ...string) (any, error) {
+	c, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultRedisTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+
+	reply, err := c.do(args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		c.conn.Close()
+		return nil, err
+	}
+	p.put(c)
+	return reply, err
+}
+
+// get takes an idle connection or dials a new one, authenticating and selecting the database.
+func (p *RedisProvider) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-p.pool:
+		return c, nil
+	default:
+	}
+
+	conn, err := p.dialer.DialContext(ctx, "tcp", p.conf.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if p.conf.Password != "" {
+		if _, err := c.do("AUTH", p.conf.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if p.conf.DB != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(p.conf.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	zlog.LOG.Debug("UserFeature.RedisProvider.Dial",
+		zap.String("name", p.conf.Name),
+		zap.String("addr", p.conf.Addr))
+	return c, nil
+}
+
+// put returns a connection to the pool, closing it if the pool is full.
+func (p *RedisProvider) put(c *redisConn) {
+	select {
+	case p.pool <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+// do writes a command as an array of bulk strings and reads its reply.
+func (c *redisConn) do(args ...string) (any, error) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply reads one RESP reply: simple strings and bulk strings become string,
+// integers int64, arrays []any (error elements as redisError), and nil bulk strings or arrays nil.
+func (c *redisConn) readReply() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", payload)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", payload)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			item, err := c.readReply()
+			var replyErr redisError
+			if errors.As(err, &replyErr) {
+				// Keep reading so the connection stays in sync
+				item = replyErr
+			} else if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}